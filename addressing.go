@@ -0,0 +1,179 @@
+package soap
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// WSANamespace is the WS-Addressing 1.0 namespace.
+const WSANamespace = "http://www.w3.org/2005/08/addressing"
+
+// Addressing holds the WS-Addressing message properties carried in a SOAP
+// Header. Set as Client.Header, or among Client.Headers, it encodes its
+// properties as WS-Addressing 1.0 blocks, with a fresh "urn:uuid:" MessageID
+// for every request if MessageID is empty; with ActionAddressing set, the
+// Action of the call replaces its own. Passed to WithResponseHeader it
+// receives those of the response, for correlating it with the request.
+// Elements are then matched by local name, so both the 1.0 and the 2004
+// submission namespaces are accepted.
+type Addressing struct {
+	Action    string `xml:"Action,omitempty"`
+	MessageID string `xml:"MessageID,omitempty"`
+	To        string `xml:"To,omitempty"`
+	ReplyTo   string `xml:"ReplyTo>Address,omitempty"` // address of the ReplyTo endpoint reference
+	RelatesTo string `xml:"RelatesTo,omitempty"`
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (a Addressing) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if a.MessageID == "" {
+		id, err := newMessageID()
+		if err != nil {
+			return err
+		}
+		a.MessageID = id
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	xmlns := []xml.Attr{{Name: xml.Name{Local: "xmlns:wsa"}, Value: WSANamespace}}
+	for _, p := range []struct{ name, value string }{
+		{"wsa:Action", a.Action},
+		{"wsa:MessageID", a.MessageID},
+		{"wsa:To", a.To},
+	} {
+		if p.value == "" {
+			continue
+		}
+		if err := e.EncodeElement(p.value, xml.StartElement{Name: xml.Name{Local: p.name}, Attr: xmlns}); err != nil {
+			return err
+		}
+	}
+	if a.ReplyTo != "" {
+		replyTo := xml.StartElement{Name: xml.Name{Local: "wsa:ReplyTo"}, Attr: xmlns}
+		if err := e.EncodeToken(replyTo); err != nil {
+			return err
+		}
+		if err := encodeText(e, "wsa:Address", a.ReplyTo); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(replyTo.End()); err != nil {
+			return err
+		}
+	}
+	if a.RelatesTo != "" {
+		relatesTo := xml.StartElement{Name: xml.Name{Local: "wsa:RelatesTo"}, Attr: xmlns}
+		if err := e.EncodeElement(a.RelatesTo, relatesTo); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// newMessageID returns a random (version 4) UUID URN.
+func newMessageID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// actionHeader is a Header holding the blocks of h followed by a wsa:Action
+// block for action. A WS-Addressing Action block of h, as an Addressing
+// with Action set encodes, is dropped in favor of it: a message carries
+// only one.
+type actionHeader struct {
+	h      Header
+	action string
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (ah actionHeader) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var toks []xml.Token
+	if ah.h != nil {
+		var err error
+		if toks, err = literalTokens(ah.h, start); err != nil {
+			return err
+		}
+	}
+	if len(toks) == 0 {
+		toks = []xml.Token{start, start.End()}
+	}
+	toks = dropAddressingAction(toks)
+	block := xml.StartElement{
+		Name: xml.Name{Local: "wsa:Action"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:wsa"}, Value: WSANamespace}},
+	}
+	last := len(toks) - 1
+	for _, tok := range toks[:last] {
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+	if err := e.EncodeElement(ah.action, block); err != nil {
+		return err
+	}
+	return e.EncodeToken(toks[last])
+}
+
+// wsaSubmissionNamespace is the namespace of the 2004 submission of
+// WS-Addressing, still used by some servers.
+const wsaSubmissionNamespace = "http://schemas.xmlsoap.org/ws/2004/08/addressing"
+
+// dropAddressingAction returns the tokens of a Header element without its
+// WS-Addressing Action blocks.
+func dropAddressingAction(toks []xml.Token) []xml.Token {
+	header := toks[0].(xml.StartElement)
+	kept := toks[:1:1]
+	for i, depth := 1, 1; i < len(toks); i++ {
+		switch t := toks[i].(type) {
+		case xml.StartElement:
+			if depth == 1 && isAddressingAction(header, t) {
+				for skip := 1; skip > 0; {
+					i++
+					switch toks[i].(type) {
+					case xml.StartElement:
+						skip++
+					case xml.EndElement:
+						skip--
+					}
+				}
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		kept = append(kept, toks[i])
+	}
+	return kept
+}
+
+// isAddressingAction reports whether el, a literal child of the Header
+// element header, is a WS-Addressing Action block.
+func isAddressingAction(header, el xml.StartElement) bool {
+	prefix, local := "", el.Name.Local
+	if i := strings.Index(local, ":"); i >= 0 {
+		prefix, local = local[:i], local[i+1:]
+	}
+	if local != "Action" {
+		return false
+	}
+	attr := "xmlns"
+	if prefix != "" {
+		attr += ":" + prefix
+	}
+	for _, attrs := range [][]xml.Attr{el.Attr, header.Attr} {
+		for _, a := range attrs {
+			if a.Name.Local == attr {
+				return a.Value == WSANamespace || a.Value == wsaSubmissionNamespace
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package soap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressingActionPlacement(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *Client
+	}{
+		{"Header", &Client{Header: Addressing{Action: "urn:header", To: "urn:to", MessageID: "urn:id"}}},
+		{"Headers", &Client{Headers: []Header{AuthHeader{Username: "u"}, &Addressing{Action: "urn:header", MessageID: "urn:id"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.ActionPlacement = ActionAll
+			b, err := tt.client.encode(tt.client.envelope(newCallOptions(nil), "urn:call", &ping{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(b)
+			if n := strings.Count(got, "<wsa:Action "); n != 1 {
+				t.Errorf("%d wsa:Action blocks, want 1:\n%s", n, got)
+			}
+			if !strings.Contains(got, `>urn:call</wsa:Action>`) {
+				t.Errorf("wsa:Action of the call missing:\n%s", got)
+			}
+			if !strings.Contains(got, `>urn:id</wsa:MessageID>`) {
+				t.Errorf("other addressing blocks dropped:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package soap
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// BasicAuth sends every request of the Client with HTTP Basic
+// authentication. It must not be called concurrently with calls.
+func (c *Client) BasicAuth(user, pass string) {
+	c.auth = &credentials{user: user, pass: pass}
+}
+
+// DigestAuth sends the requests of the Client with HTTP Digest
+// authentication: a request answered with a 401 Digest challenge is sent
+// again with the computed Authorization, which later requests reuse until
+// the server issues a new challenge. Requests that cannot be sent twice,
+// such as streamed ones, are only authorized once a challenge is known. It
+// must not be called concurrently with calls.
+func (c *Client) DigestAuth(user, pass string) {
+	c.auth = &credentials{user: user, pass: pass, digest: true}
+}
+
+// credentials authenticate the requests of a Client.
+type credentials struct {
+	user, pass string
+	digest     bool
+
+	mu        sync.Mutex
+	challenge map[string]string // parameters of the last Digest challenge
+	count     int               // requests sent with the nonce of challenge
+}
+
+// authDoer is a doer authenticating the requests it sends.
+type authDoer struct {
+	doer
+	cred *credentials
+}
+
+// Do implements the doer interface.
+func (d authDoer) Do(r *http.Request) (*http.Response, error) {
+	if !d.cred.digest {
+		r.SetBasicAuth(d.cred.user, d.cred.pass)
+		return d.doer.Do(r)
+	}
+	if err := d.cred.authorize(r); err != nil {
+		return nil, err
+	}
+	resp, err := d.doer.Do(r)
+	empty := r.Body == nil || r.Body == http.NoBody
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || (r.GetBody == nil && !empty) {
+		return resp, err
+	}
+	challenge, ok := parseDigestChallenge(resp.Header.Get("WWW-Authenticate"))
+	if !ok {
+		return resp, nil
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	d.cred.setChallenge(challenge)
+	retry := r.Clone(r.Context())
+	if !empty {
+		if retry.Body, err = r.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.cred.authorize(retry); err != nil {
+		return nil, err
+	}
+	return d.doer.Do(retry)
+}
+
+// setChallenge records a new Digest challenge.
+func (cred *credentials) setChallenge(challenge map[string]string) {
+	cred.mu.Lock()
+	defer cred.mu.Unlock()
+	cred.challenge = challenge
+	cred.count = 0
+}
+
+// authorize sets the Digest Authorization of r, if a challenge is known.
+func (cred *credentials) authorize(r *http.Request) error {
+	cred.mu.Lock()
+	challenge := cred.challenge
+	cred.count++
+	count := cred.count
+	cred.mu.Unlock()
+	if challenge == nil {
+		return nil
+	}
+
+	algorithm := challenge["algorithm"]
+	sess := strings.HasSuffix(strings.ToUpper(algorithm), "-SESS")
+	var h func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return fmt.Errorf("soap: unsupported digest algorithm %q", algorithm)
+	}
+	digest := func(parts ...string) string {
+		sum := h()
+		io.WriteString(sum, strings.Join(parts, ":"))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+	cnonce, err := newCnonce()
+	if err != nil {
+		return err
+	}
+	nc := fmt.Sprintf("%08x", count)
+	realm, nonce, uri := challenge["realm"], challenge["nonce"], r.URL.RequestURI()
+
+	ha1 := digest(cred.user, realm, cred.pass)
+	if sess {
+		ha1 = digest(ha1, nonce, cnonce)
+	}
+	ha2 := digest(r.Method, uri)
+	qop := ""
+	for _, q := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop != "" {
+		response = digest(ha1, nonce, nc, cnonce, qop, ha2)
+	} else {
+		response = digest(ha1, nonce, ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		cred.user, realm, nonce, uri, response)
+	if algorithm != "" {
+		auth += ", algorithm=" + algorithm
+	}
+	if qop != "" {
+		auth += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		auth += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	r.Header.Set("Authorization", auth)
+	return nil
+}
+
+// newCnonce returns the client nonce of a Digest Authorization; tests
+// replace it to check known responses.
+var newCnonce = func() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseDigestChallenge returns the parameters of a WWW-Authenticate header
+// holding a Digest challenge.
+func parseDigestChallenge(header string) (map[string]string, bool) {
+	const scheme = "digest "
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	s := header[len(scheme):]
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			break
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1:end])
+			s = s[end+1:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	_, ok := params["nonce"]
+	return params, ok
+}
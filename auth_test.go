@@ -0,0 +1,93 @@
+package soap
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDigestAuthorization(t *testing.T) {
+	defer func(f func() (string, error)) { newCnonce = f }(newCnonce)
+
+	tests := []struct {
+		name       string
+		user, pass string
+		challenge  string
+		cnonce     string
+		want       string
+	}{
+		{
+			// RFC 2617, section 3.5
+			name: "qop=auth", user: "Mufasa", pass: "Circle Of Life",
+			challenge: `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			cnonce:    "0a4f113b",
+			want:      `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", response="6629fae49393a05397450978507c4ef1", qop=auth, nc=00000001, cnonce="0a4f113b", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+		},
+		{
+			// RFC 2069, section 2.4, which has no qop
+			name: "no qop", user: "Mufasa", pass: "CircleOfLife",
+			challenge: `Digest realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			cnonce:    "0a4f113b",
+			want:      `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", response="1949323746fe6a43ef61f9606e7febea", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+		},
+		{
+			// the RFC 2617 example with algorithm=MD5-sess, for which the
+			// RFC gives no response
+			name: "MD5-sess", user: "Mufasa", pass: "Circle Of Life",
+			challenge: `Digest realm="testrealm@host.com", qop="auth", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41", algorithm=MD5-sess`,
+			cnonce:    "0a4f113b",
+			want:      `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", response="8e3825c57e897f5a0dec6c2d4e5059d0", algorithm=MD5-sess, qop=auth, nc=00000001, cnonce="0a4f113b", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+		},
+		{
+			// RFC 7616, section 3.9.1
+			name: "SHA-256", user: "Mufasa", pass: "Circle of Life",
+			challenge: `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			cnonce:    "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+			want:      `Digest username="Mufasa", realm="http-auth@example.org", nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", uri="/dir/index.html", response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", algorithm=SHA-256, qop=auth, nc=00000001, cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cnonce := tt.cnonce
+			newCnonce = func() (string, error) { return cnonce, nil }
+			challenge, ok := parseDigestChallenge(tt.challenge)
+			if !ok {
+				t.Fatalf("parseDigestChallenge(%q) failed", tt.challenge)
+			}
+			cred := &credentials{user: tt.user, pass: tt.pass, digest: true}
+			cred.setChallenge(challenge)
+			r, err := http.NewRequest(http.MethodGet, "http://example.org/dir/index.html", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cred.authorize(r); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestAuthRejectedTwice(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("WWW-Authenticate", `Digest realm="soap", nonce="abc", qop="auth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	c.DigestAuth("user", "wrong")
+	err := c.RoundTrip(&ping{}, &ping{})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got error %v, want a 401 *HTTPError", err)
+	}
+	if requests != 2 {
+		t.Errorf("server got %d requests, want 2", requests)
+	}
+}
@@ -0,0 +1,30 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/base64"
+)
+
+// Base64Binary is binary data encoded as xsd:base64Binary text, unlike a
+// plain []byte, which encoding/xml reads and writes as raw text. Line
+// breaks and other whitespace in the text received are ignored.
+type Base64Binary []byte
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (b Base64Binary) MarshalText() ([]byte, error) {
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(text, b)
+	return text, nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (b *Base64Binary) UnmarshalText(text []byte) error {
+	text = bytes.Join(bytes.Fields(text), nil)
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(data, text)
+	if err != nil {
+		return err
+	}
+	*b = data[:n]
+	return nil
+}
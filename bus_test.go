@@ -0,0 +1,115 @@
+package soap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBusClientContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		fails       bool
+	}{
+		{"application/json", false},
+		{"text/plain; charset=utf-8", false},
+		{"", false},
+		{"text/html; charset=utf-8", true},
+	}
+	for _, tt := range tests {
+		srv := serve(http.StatusOK, tt.contentType, `{"ok":true}`)
+		c := &BusClient{BaseURL: srv.URL, MethodName: "/call"}
+		b, err := c.RoundTripWithBus("", []byte(`{}`))
+		srv.Close()
+		var ctErr *ContentTypeError
+		if tt.fails {
+			if !errors.As(err, &ctErr) {
+				t.Errorf("%q: got error %v, want a *ContentTypeError", tt.contentType, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.contentType, err)
+		} else if string(b) != `{"ok":true}` {
+			t.Errorf("%q: got body %q", tt.contentType, b)
+		}
+	}
+}
+
+func TestBusClientKeepalive(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     BusClient
+		connection string
+	}{
+		{"default", BusClient{}, ""},
+		{"Keepalive", BusClient{Keepalive: true}, "keep-alive"},
+		{"NoKeepalive", BusClient{NoKeepalive: true}, "close"},
+	}
+	for _, tt := range tests {
+		var got string
+		var closed bool
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, closed = r.Header.Get("Connection"), r.Close
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		}))
+		c := tt.client
+		c.BaseURL = srv.URL
+		_, err := c.RoundTripWithBus("", []byte(`{}`))
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if closed != (tt.connection == "close") {
+			t.Errorf("%s: request closed the connection: %v", tt.name, closed)
+		}
+		if tt.connection != "close" && got != tt.connection {
+			t.Errorf("%s: Connection = %q, want %q", tt.name, got, tt.connection)
+		}
+	}
+}
+
+func TestBusClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hanging.Close()
+	defer close(done)
+	unavailable := serve(http.StatusServiceUnavailable, "text/plain", "busy")
+	defer unavailable.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		retries int // OnRetry calls expected
+	}{
+		{"attempt", hanging.URL, 0},
+		{"wait for a retry", unavailable.URL, 1},
+	}
+	for _, tt := range tests {
+		retries := 0
+		c := &BusClient{
+			BaseURL: tt.url,
+			Timeout: 50 * time.Millisecond,
+			Retry:   &RetryPolicy{MaxAttempts: 5, Backoff: time.Second, Jitter: NoJitter},
+			OnRetry: func(attempt int, delay time.Duration, err error) { retries++ },
+		}
+		_, err := c.RoundTripWithBus("/call", []byte(`{}`))
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) || !transportErr.Aborted {
+			t.Errorf("%s: got error %v, want an aborted *TransportError", tt.name, err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: errors.Is(%v, context.DeadlineExceeded) = false", tt.name, err)
+		}
+		if retries != tt.retries {
+			t.Errorf("%s: OnRetry called %d times, want %d", tt.name, retries, tt.retries)
+		}
+	}
+}
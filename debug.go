@@ -0,0 +1,70 @@
+package soap
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// operationKey is the context key of the action of a call.
+type operationKey struct{}
+
+// OperationFromContext returns the action of the SOAP call whose context is
+// passed to Client.LogContext, or "" if there is none.
+func OperationFromContext(ctx context.Context) string {
+	action, _ := ctx.Value(operationKey{}).(string)
+	return action
+}
+
+// debug logs the envelope b when Debug is set, masked by Redact. The bytes
+// sent or decoded are left untouched.
+func (c *Client) debug(ctx context.Context, what string, b []byte) {
+	if !c.Debug {
+		return
+	}
+	if c.Redact != nil {
+		b = c.Redact(append([]byte(nil), b...))
+	}
+	if c.LogContext != nil {
+		c.LogContext(ctx, "soap: %s %s: %s", what, c.URL, b)
+		return
+	}
+	logf := c.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+	logf("soap: %s %s: %s", what, c.URL, b)
+}
+
+// sent reports the serialized request envelope b to Debug and
+// OnRequestBody.
+func (c *Client) sent(ctx context.Context, b []byte) {
+	c.debug(ctx, "request", b)
+	if c.OnRequestBody != nil {
+		c.OnRequestBody(b)
+	}
+}
+
+// received reports the response body b to Debug and OnResponseBody.
+func (c *Client) received(ctx context.Context, b []byte) {
+	c.debug(ctx, "response", b)
+	if c.OnResponseBody != nil {
+		c.OnResponseBody(b)
+	}
+}
+
+// RedactElements returns a Redact function masking the text content of
+// every element with one of the given local names, whatever its prefix.
+// For instance RedactElements("Password", "CardNumber") turns
+// <ns:Password>secret</ns:Password> into <ns:Password>***</ns:Password>.
+func RedactElements(names ...string) func([]byte) []byte {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	re := regexp.MustCompile(`(<(?:[\w.\-]+:)?(?:` + strings.Join(quoted, "|") + `)(?:\s[^>]*)?>)[^<]+`)
+	return func(b []byte) []byte {
+		return re.ReplaceAll(b, []byte("${1}***"))
+	}
+}
@@ -0,0 +1,541 @@
+package soap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// responseDecoder holds the settings used to decode a response envelope.
+type responseDecoder struct {
+	forceFault bool                // decode the first Body child as a Fault whatever its name
+	renames    map[string]string   // element local names to rewrite while decoding out
+	foldCase   bool                // match the Envelope, Header, Body and Fault element names case-insensitively
+	header     Message             // destination of the response Header, if any
+	maxElems   int                 // maximum number of elements in the response, if positive
+	expected   xml.Name            // required name of the first Body child, if Local is set
+	bare       bool                // decode the Body onto out whatever the XMLName of out
+	prefixes   map[string]string   // prefixes by namespace, named literally in the elements decoded onto out
+	trimSpace  bool                // drop whitespace-only text decoded onto out
+	envNS      string              // required namespace of the Envelope element, if set
+	severities map[string]Severity // classification of Body elements by local name
+	charset    CharsetReader       // converter of non-UTF-8 responses, if any
+	multiRef   bool                // resolve href/id references within the Body before decoding out
+	detail     Message             // destination of the detail of a fault, if any
+
+	elems      int             // number of elements read so far
+	entries    []ResponseEntry // entries classified by severities, in document order
+	entry      *ResponseEntry  // entry being read
+	entryDepth int
+}
+
+// CharsetReader converts input from the charset declared by its XML
+// declaration to UTF-8, as xml.Decoder.CharsetReader does.
+type CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// ElementLimitError is returned when a response holds more elements than
+// allowed by Client.MaxElements.
+type ElementLimitError struct {
+	Limit int
+}
+
+func (e *ElementLimitError) Error() string {
+	return fmt.Sprintf("soap: response exceeds %d elements", e.Limit)
+}
+
+// ResponseElementError is returned when the response Body does not hold
+// the element expected by WithExpectedResponse. Got is the zero Name if
+// the Body is empty.
+type ResponseElementError struct {
+	Expected xml.Name
+	Got      xml.Name
+}
+
+func (e *ResponseElementError) Error() string {
+	return fmt.Sprintf("soap: expected response element <%s> but have <%s>", e.Expected.Local, e.Got.Local)
+}
+
+// decodeError wraps an error of responseDecoder.decode in a *DecodeError,
+// unless it describes the response itself, as a Fault does, or comes from
+// reading it, in which case it is returned as is.
+func decodeError(err error) error {
+	switch err.(type) {
+	case *Fault, *MustUnderstandFault, *ElementLimitError, *ResponseElementError, *ResponseSizeError, *TransportError:
+		return err
+	}
+	return &DecodeError{Err: err}
+}
+
+// is reports whether name has the given local name, one of the SOAP
+// envelope element names.
+func (rd *responseDecoder) is(name xml.Name, local string) bool {
+	if rd.foldCase {
+		return strings.EqualFold(name.Local, local)
+	}
+	return name.Local == local
+}
+
+// count records an element of the response Body, failing once the limit
+// is exceeded.
+func (rd *responseDecoder) count() error {
+	rd.elems++
+	if rd.maxElems > 0 && rd.elems > rd.maxElems {
+		return &ElementLimitError{Limit: rd.maxElems}
+	}
+	return nil
+}
+
+// decode decodes the SOAP envelope read from r onto out. The envelope
+// elements are matched by local name, whatever their namespace and prefix,
+// unless envNS is set. The Body is checked for a Fault before out is
+// touched: the envelope is walked up to the first Body child, and only if
+// that child is not a Fault is the document decoded again, from the start,
+// onto out. The bytes consumed by the first walk are kept and replayed so r
+// is read only once.
+func (rd *responseDecoder) decode(r io.Reader, out Message) error {
+	var seen bytes.Buffer
+	d := rd.newDecoder(io.TeeReader(r, &seen))
+	header, body, err := rd.findBody(d, true)
+	if err != nil || body == nil {
+		return err
+	}
+	if rd.header != nil && header != nil {
+		if err := decodeTokens(header, rd.header); err != nil {
+			return err
+		}
+	}
+	child, err := firstChild(d)
+	if err != nil {
+		return err
+	}
+	if child == nil {
+		if rd.forceFault {
+			return errors.New("soap: fault signalled but the response Body is empty")
+		}
+		return rd.check(xml.Name{})
+	}
+	if rd.forceFault || rd.is(child.Name, "Fault") {
+		f := new(Fault)
+		if err := d.DecodeElement(f, child); err != nil {
+			return err
+		}
+		if rd.detail != nil && f.Detail != nil {
+			if err := rd.decodeFaultDetail(io.MultiReader(&seen, r)); err != nil {
+				return err
+			}
+		}
+		return faultError(f, header)
+	}
+	if err := rd.check(child.Name); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+
+	var counter *elementCounter
+	if rd.maxElems > 0 && !rd.multiRef && !rd.transforms() {
+		// count the elements in the text read by d, so that out is still
+		// decoded from the source text, ",innerxml" fields included
+		d, counter = rd.newCountingDecoder(io.MultiReader(&seen, r))
+	} else {
+		d = rd.newDecoder(io.MultiReader(&seen, r))
+	}
+	if _, body, err = rd.findBody(d, false); err != nil {
+		return err
+	}
+	if counter != nil {
+		*counter.on = true
+	}
+	if rd.bare {
+		if name, ok := elementName(out); ok {
+			body.Name = name
+		}
+	}
+	var src xml.TokenReader = d
+	if rd.multiRef {
+		content, err := rd.readTokens(d, true)
+		if err != nil {
+			return err
+		}
+		rd.elems = 0 // counted again once resolved
+		src = newRefTokens(append(content, body.End()))
+	} else if !rd.transforms() {
+		return d.DecodeElement(out, body)
+	}
+	return xml.NewTokenDecoder(&bodyTokens{rd: rd, d: src, start: body}).Decode(out)
+}
+
+// decodeFaultDetail decodes the detail element of the fault of the
+// response read from r onto faultDetail.
+func (rd *responseDecoder) decodeFaultDetail(r io.Reader) error {
+	d := rd.newDecoder(r)
+	if _, body, err := rd.findBody(d, false); err != nil || body == nil {
+		return err
+	}
+	if _, err := firstChild(d); err != nil {
+		return err
+	}
+	for {
+		el, err := firstChild(d)
+		if err != nil || el == nil {
+			return err
+		}
+		if strings.EqualFold(el.Name.Local, "detail") {
+			return d.DecodeElement(rd.detail, el)
+		}
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+}
+
+// newDecoder returns an XML decoder reading r.
+func (rd *responseDecoder) newDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = rd.charset
+	return d
+}
+
+// newCountingDecoder is like newDecoder, counting the start tags of the
+// text d reads once *counter.on is set. The counter scans the text the
+// decoder is fed, after conversion from another charset if need be, and
+// reads it a byte at a time so that the decoder does not buffer ahead.
+func (rd *responseDecoder) newCountingDecoder(r io.Reader) (d *xml.Decoder, counter *elementCounter) {
+	counter = &elementCounter{r: bufio.NewReader(r), rd: rd, on: new(bool)}
+	d = xml.NewDecoder(counter)
+	if rd.charset != nil {
+		d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+			conv, err := rd.charset(label, input)
+			if err != nil {
+				return nil, err
+			}
+			return &elementCounter{r: bufio.NewReader(conv), rd: rd, on: counter.on}, nil
+		}
+	}
+	return d, counter
+}
+
+// elementCounter is an io.ByteReader passing text through, and counting
+// the start tags it holds with rd.count while on is set. Markup that cannot
+// hold elements, such as comments, CDATA sections and processing
+// instructions, is skipped.
+type elementCounter struct {
+	r  *bufio.Reader
+	rd *responseDecoder
+	on *bool
+
+	lt   bool   // whether the last byte opened a tag
+	bang bool   // whether the last bytes were "<!"
+	end  string // end of the skipped markup, if in one
+	tail []byte // last bytes of the skipped markup
+}
+
+// Read implements the io.Reader interface, for a charset converter reading
+// the raw text. The text it reads is not counted.
+func (ec *elementCounter) Read(p []byte) (int, error) {
+	return ec.r.Read(p)
+}
+
+// ReadByte implements the io.ByteReader interface.
+func (ec *elementCounter) ReadByte() (byte, error) {
+	c, err := ec.r.ReadByte()
+	if err != nil {
+		return c, err
+	}
+	switch {
+	case ec.end != "":
+		ec.tail = append(ec.tail, c)
+		if len(ec.tail) > len(ec.end) {
+			ec.tail = ec.tail[1:]
+		}
+		if string(ec.tail) == ec.end {
+			ec.end, ec.tail = "", ec.tail[:0]
+		}
+	case ec.bang:
+		ec.bang = false
+		switch c {
+		case '-':
+			ec.end = "-->"
+		case '[':
+			ec.end = "]]>"
+		default:
+			ec.end = ">" // declaration
+		}
+	case ec.lt:
+		ec.lt = false
+		switch c {
+		case '/':
+		case '?':
+			ec.end = "?>"
+		case '!':
+			ec.bang = true
+		default:
+			if *ec.on {
+				if err := ec.rd.count(); err != nil {
+					return 0, err
+				}
+			}
+		}
+	case c == '<':
+		ec.lt = true
+	}
+	return c, nil
+}
+
+// check verifies that the first Body child has the expected name.
+func (rd *responseDecoder) check(name xml.Name) error {
+	if rd.expected.Local == "" {
+		return nil
+	}
+	local := rd.expected.Local
+	if i := strings.LastIndex(local, ":"); i >= 0 {
+		local = local[i+1:] // the prefix is not checked
+	}
+	if name.Local != local || (rd.expected.Space != "" && name.Space != rd.expected.Space) {
+		return &ResponseElementError{Expected: rd.expected, Got: name}
+	}
+	return nil
+}
+
+// elementName returns the name given by the XMLName field of the struct
+// v points to, if any.
+func elementName(v interface{}) (xml.Name, bool) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return xml.Name{}, false
+	}
+	f, ok := t.FieldByName("XMLName")
+	if !ok {
+		return xml.Name{}, false
+	}
+	name := strings.Split(f.Tag.Get("xml"), ",")[0]
+	if name == "" {
+		return xml.Name{}, false
+	}
+	return tagName(name), true
+}
+
+// transforms reports whether tokens must be rewritten while decoding out.
+// The rewritten tokens are decoded without their source text, so ",innerxml"
+// fields of out are left empty in that case. Elements are then counted as
+// they are rewritten, and otherwise by an elementCounter.
+func (rd *responseDecoder) transforms() bool {
+	return len(rd.renames) > 0 || len(rd.prefixes) > 0 || rd.trimSpace || len(rd.severities) > 0
+}
+
+// transform rewrites tok according to the decoder settings.
+func (rd *responseDecoder) transform(tok xml.Token) xml.Token {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		t.Name = rd.rename(t.Name)
+		return t
+	case xml.EndElement:
+		t.Name = rd.rename(t.Name)
+		return t
+	}
+	return tok
+}
+
+// rename applies ResponseNames to an element name, then writes the prefix
+// of its namespace, if it has one in Namespaces, into its local part. Struct
+// tags naming elements as "prefix:local" thus match both ways.
+func (rd *responseDecoder) rename(n xml.Name) xml.Name {
+	if name, ok := rd.renames[n.Local]; ok {
+		n.Local = name
+	}
+	if prefix, ok := rd.prefixes[n.Space]; ok {
+		n = xml.Name{Local: prefix + ":" + n.Local}
+	}
+	return n
+}
+
+// prefixes inverts a map of namespaces by prefix.
+func prefixes(namespaces map[string]string) map[string]string {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(namespaces))
+	for prefix, uri := range namespaces {
+		m[uri] = prefix
+	}
+	return m
+}
+
+// bodyTokens is an xml.TokenReader yielding start, then the tokens read
+// from d up to the matching end element, each rewritten (or, for blank
+// text, dropped) by rd. The end element is given the name of start.
+type bodyTokens struct {
+	rd    *responseDecoder
+	d     xml.TokenReader
+	start *xml.StartElement
+	name  xml.Name
+	depth int
+}
+
+// Token implements the xml.TokenReader interface.
+func (b *bodyTokens) Token() (xml.Token, error) {
+	var tok xml.Token
+	switch {
+	case b.start != nil:
+		tok, b.name, b.start = *b.start, b.start.Name, nil
+	case b.depth == 0:
+		return nil, io.EOF
+	default:
+		for {
+			var err error
+			if tok, err = b.d.Token(); err != nil {
+				return nil, err
+			}
+			if t, ok := tok.(xml.CharData); !ok || !b.rd.trimSpace || len(bytes.TrimSpace(t)) > 0 {
+				break
+			}
+		}
+	}
+	switch tok.(type) {
+	case xml.StartElement:
+		if b.depth > 0 { // not the Body itself
+			if err := b.rd.count(); err != nil {
+				return nil, err
+			}
+		}
+		b.depth++
+	case xml.EndElement:
+		if b.depth--; b.depth == 0 {
+			tok = xml.EndElement{Name: b.name}
+		}
+	}
+	b.rd.observe(tok, b.depth)
+	return b.rd.transform(tok), nil
+}
+
+// findBody advances d to the Body element of the envelope and returns its
+// start element, along with the tokens of the Header element, start and
+// end included, if one precedes it and header is set. A nil start element
+// means the envelope has no Body.
+func (rd *responseDecoder) findBody(d *xml.Decoder, header bool) ([]xml.Token, *xml.StartElement, error) {
+	var toks []xml.Token
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF && depth > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if !rd.is(t.Name, "Envelope") {
+					return nil, nil, fmt.Errorf("expected element type <Envelope> but have <%s>", t.Name.Local)
+				}
+				if rd.envNS != "" && t.Name.Space != rd.envNS {
+					return nil, nil, fmt.Errorf("soap: response envelope namespace is %q, expected %q", t.Name.Space, rd.envNS)
+				}
+				depth++
+				continue
+			}
+			switch {
+			case rd.is(t.Name, "Header") && header:
+				start := xml.CopyToken(t).(xml.StartElement)
+				content, err := rd.readTokens(d, false)
+				if err != nil {
+					return nil, nil, err
+				}
+				toks = append(append([]xml.Token{start}, content...), start.End())
+			case rd.is(t.Name, "Body"):
+				start := xml.CopyToken(t).(xml.StartElement)
+				return toks, &start, nil
+			default:
+				if err := d.Skip(); err != nil {
+					return nil, nil, err
+				}
+			}
+		case xml.EndElement:
+			return toks, nil, nil
+		}
+	}
+}
+
+// decodeTokens decodes v from the recorded tokens of an element.
+func decodeTokens(toks []xml.Token, v interface{}) error {
+	return xml.NewTokenDecoder(&tokenSlice{toks: toks}).Decode(v)
+}
+
+// tokenSlice is an xml.TokenReader over recorded tokens.
+type tokenSlice struct {
+	toks []xml.Token
+}
+
+// Token implements the xml.TokenReader interface.
+func (s *tokenSlice) Token() (xml.Token, error) {
+	if len(s.toks) == 0 {
+		return nil, io.EOF
+	}
+	tok := s.toks[0]
+	s.toks = s.toks[1:]
+	return tok, nil
+}
+
+// firstChild returns the first child element of the element whose start
+// has just been read from d, or nil if it has none. In the latter case the
+// end element is consumed.
+func firstChild(d *xml.Decoder) (*xml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			start := xml.CopyToken(t).(xml.StartElement)
+			return &start, nil
+		case xml.EndElement:
+			return nil, nil
+		}
+	}
+}
+
+// readTokens copies the tokens of the element whose start has just been read
+// from d, up to but excluding its end element, counting its elements if
+// count is set.
+func (rd *responseDecoder) readTokens(d *xml.Decoder, count bool) ([]xml.Token, error) {
+	var toks []xml.Token
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return toks, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return toks, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			if count {
+				if err := rd.count(); err != nil {
+					return toks, err
+				}
+			}
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return toks, nil
+			}
+			depth--
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+}
@@ -0,0 +1,98 @@
+package soap
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const quoteResponse = `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body>` +
+	`<GetQuoteResponse><Price>1</Price><Price>2</Price></GetQuoteResponse>` +
+	`</soapenv:Body></soapenv:Envelope>`
+
+// rawBody keeps the source text of a Body.
+type rawBody struct {
+	XML string `xml:",innerxml"`
+}
+
+func TestMaxElementsKeepsInnerXML(t *testing.T) {
+	for _, maxElems := range []int{0, 5} {
+		rd := &responseDecoder{maxElems: maxElems}
+		var out rawBody
+		if err := rd.decode(strings.NewReader(quoteResponse), &out); err != nil {
+			t.Fatalf("MaxElements %d: %v", maxElems, err)
+		}
+		if want := `<GetQuoteResponse><Price>1</Price><Price>2</Price></GetQuoteResponse>`; out.XML != want {
+			t.Errorf("MaxElements %d: innerxml = %q, want %q", maxElems, out.XML, want)
+		}
+	}
+}
+
+func TestMaxElementsLimit(t *testing.T) {
+	withHeader := `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">` +
+		`<soapenv:Header><A><B/><C/><D/></A></soapenv:Header>` +
+		`<soapenv:Body><R><!-- <X/> --><![CDATA[<Y/>]]><?pi <Z/>?></R></soapenv:Body></soapenv:Envelope>`
+	tests := []struct {
+		name     string
+		response string
+		maxElems int
+		ok       bool
+	}{
+		// GetQuoteResponse and two Price elements; the Body is not counted
+		{"under", quoteResponse, 3, true},
+		{"over", quoteResponse, 2, false},
+		// neither the Header nor markup other than elements is counted
+		{"header", withHeader, 1, true},
+	}
+	for _, tt := range tests {
+		for _, trim := range []bool{false, true} {
+			rd := &responseDecoder{maxElems: tt.maxElems, trimSpace: trim}
+			err := rd.decode(strings.NewReader(tt.response), &rawBody{})
+			var limitErr *ElementLimitError
+			if tt.ok && err != nil {
+				t.Errorf("%s (trim %v): %v", tt.name, trim, err)
+			}
+			if !tt.ok && !errors.As(err, &limitErr) {
+				t.Errorf("%s (trim %v): got error %v, want an *ElementLimitError", tt.name, trim, err)
+			}
+		}
+	}
+}
+
+func TestMaxElementsStreaming(t *testing.T) {
+	// a Body far larger than the limit fails before it is read through
+	var b strings.Builder
+	b.WriteString(`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><R>`)
+	for i := 0; i < 100000; i++ {
+		b.WriteString("<Item>1</Item>")
+	}
+	b.WriteString(`</R></soapenv:Body></soapenv:Envelope>`)
+	r := strings.NewReader(b.String())
+	rd := &responseDecoder{maxElems: 10}
+	err := rd.decode(r, &rawBody{})
+	var limitErr *ElementLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("got error %v, want an *ElementLimitError", err)
+	}
+	if r.Len() < b.Len()/2 {
+		t.Errorf("%d of %d bytes read before failing", b.Len()-r.Len(), b.Len())
+	}
+}
+
+func TestMaxElementsCharset(t *testing.T) {
+	response := `<?xml version="1.0" encoding="ISO-8859-1"?>` + quoteResponse
+	identity := func(label string, input io.Reader) (io.Reader, error) { return input, nil }
+	for maxElems, ok := range map[int]bool{2: false, 3: true} {
+		rd := &responseDecoder{maxElems: maxElems, charset: identity}
+		var out rawBody
+		err := rd.decode(strings.NewReader(response), &out)
+		var limitErr *ElementLimitError
+		if ok && (err != nil || out.XML == "") {
+			t.Errorf("MaxElements %d: got error %v and innerxml %q", maxElems, err, out.XML)
+		}
+		if !ok && !errors.As(err, &limitErr) {
+			t.Errorf("MaxElements %d: got error %v, want an *ElementLimitError", maxElems, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package soap
+
+import (
+	"encoding"
+	"encoding/xml"
+	"reflect"
+	"strings"
+)
+
+// EmptyPolicy controls how the nil pointers, nil interfaces and empty
+// slices found in request messages are encoded. Fields tagged omitempty
+// are always left out.
+type EmptyPolicy int
+
+// Empty field policies.
+const (
+	EmptyOmit    EmptyPolicy = iota // leave the element out, as encoding/xml does (default)
+	EmptyElement                    // emit an empty element
+	EmptyNil                        // emit an empty element with xsi:nil="true"
+)
+
+var (
+	marshalerType     = reflect.TypeOf((*xml.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// applyEmptyPolicy returns a copy of v in which nil and empty element
+// fields are replaced by values encoding them according to policy. Structs
+// with custom marshalers or embedded fields are kept as they are.
+func applyEmptyPolicy(v reflect.Value, policy EmptyPolicy) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if customMarshaler(v.Type()) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v.Interface()
+		}
+		return applyEmptyPolicy(v.Elem(), policy)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = applyEmptyPolicy(v.Index(i), policy)
+		}
+		return items
+	case reflect.Struct:
+		return emptyPolicyStruct(v, policy)
+	}
+	return v.Interface()
+}
+
+func emptyPolicyStruct(v reflect.Value, policy EmptyPolicy) interface{} {
+	t := v.Type()
+	var fields []reflect.StructField
+	var values []interface{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			return v.Interface()
+		}
+		tag := f.Tag.Get("xml")
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Name == "XMLName" || !elementField(tag) {
+			fields = append(fields, f)
+			values = append(values, fv.Interface())
+			continue
+		}
+		switch {
+		case isEmptyValue(fv) && !strings.Contains(tag, ",omitempty") && !strings.Contains(tag, ",any"):
+			values = append(values, emptyElement{nil: policy == EmptyNil})
+		case fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface ||
+			fv.Kind() == reflect.Struct || fv.Kind() == reflect.Slice:
+			values = append(values, applyEmptyPolicy(fv, policy))
+		default:
+			fields = append(fields, f)
+			values = append(values, fv.Interface())
+			continue
+		}
+		if tag == "" || strings.HasPrefix(tag, ",") {
+			tag = f.Name + tag
+		}
+		fields = append(fields, reflect.StructField{
+			Name: f.Name,
+			Type: reflect.TypeOf((*interface{})(nil)).Elem(),
+			Tag:  reflect.StructTag(`xml:"` + tag + `"`),
+		})
+	}
+	nv := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, val := range values {
+		if val != nil {
+			nv.Field(i).Set(reflect.ValueOf(val))
+		}
+	}
+	return nv.Interface()
+}
+
+// customMarshaler reports whether values of t encode themselves.
+func customMarshaler(t reflect.Type) bool {
+	return t.Implements(marshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// elementField reports whether a field with the given xml tag is encoded
+// as an element.
+func elementField(tag string) bool {
+	for _, flag := range strings.Split(tag, ",")[1:] {
+		switch flag {
+		case "attr", "chardata", "cdata", "innerxml", "comment":
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyValue reports whether v is a nil pointer, a nil interface or an
+// empty slice.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.Len() == 0 && v.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// emptyElement encodes as an empty element, optionally marked xsi:nil.
+type emptyElement struct {
+	nil bool
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (el emptyElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if el.nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
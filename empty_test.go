@@ -0,0 +1,89 @@
+package soap
+
+import (
+	"strings"
+	"testing"
+)
+
+type emptyItem struct {
+	Name *string `xml:"name"`
+}
+
+type EmbeddedItem struct {
+	Code *string `xml:"code"`
+}
+
+// encodeBody returns the content of the Body of the request carrying in, as
+// encoded with policy.
+func encodeBody(t *testing.T, policy EmptyPolicy, in Message) string {
+	c := &Client{EmptyPolicy: policy}
+	b, err := c.encode(c.envelope(newCallOptions(nil), "", in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	start := strings.Index(s, "<soapenv:Body>") + len("<soapenv:Body>")
+	return s[start:strings.Index(s, "</soapenv:Body>")]
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	name := "widget"
+	type pointers struct {
+		Req struct {
+			Name     *string `xml:"name"`
+			Optional *string `xml:"optional,omitempty"`
+			ID       *string `xml:"id,attr"`
+			Set      *string `xml:"set"`
+		} `xml:"Req"`
+	}
+	type nested struct {
+		Req struct {
+			Item  *emptyItem `xml:"item"`
+			Other *emptyItem `xml:"other"`
+		} `xml:"Req"`
+	}
+	type slices struct {
+		Req struct {
+			Tags  []string    `xml:"tag"`
+			Items []emptyItem `xml:"item"`
+			Bytes []byte      `xml:"bytes"`
+		} `xml:"Req"`
+	}
+	type anonymous struct {
+		Req struct {
+			EmbeddedItem
+			Name *string `xml:"name"`
+		} `xml:"Req"`
+	}
+
+	p := &pointers{}
+	p.Req.Set = &name
+	n := &nested{}
+	n.Req.Item = &emptyItem{}
+	s := &slices{}
+	s.Req.Items = []emptyItem{{}, {Name: &name}}
+
+	tests := []struct {
+		name   string
+		in     Message
+		policy EmptyPolicy
+		want   string
+	}{
+		{"pointer omit", p, EmptyOmit, `<Req><set>widget</set></Req>`},
+		{"pointer element", p, EmptyElement, `<Req><name></name><set>widget</set></Req>`},
+		{"pointer nil", p, EmptyNil, `<Req><name xsi:nil="true"></name><set>widget</set></Req>`},
+		{"nested pointer", n, EmptyNil, `<Req><item><name xsi:nil="true"></name></item><other xsi:nil="true"></other></Req>`},
+		{"slice omit", s, EmptyOmit, `<Req><item></item><item><name>widget</name></item><bytes></bytes></Req>`},
+		{"slice element", s, EmptyElement, `<Req><tag></tag><item><name></name></item><item><name>widget</name></item><bytes></bytes></Req>`},
+		{"slice nil", s, EmptyNil, `<Req><tag xsi:nil="true"></tag><item><name xsi:nil="true"></name></item><item><name>widget</name></item><bytes></bytes></Req>`},
+		// structs with embedded fields are left to encoding/xml
+		{"anonymous field", &anonymous{}, EmptyNil, `<Req></Req>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeBody(t, tt.policy, tt.in); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
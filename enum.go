@@ -0,0 +1,85 @@
+package soap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	enumMu    sync.RWMutex
+	enumTypes = make(map[string]func(int) interface{})
+)
+
+// RegisterEnum registers the conversion of an integer-backed enumeration
+// whose elements carry the given xsi:type (local name, without prefix).
+// Decoding an Enum with that type stores conv(n) in its Value.
+func RegisterEnum(xsiType string, conv func(n int) interface{}) {
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enumTypes[xsiType] = conv
+}
+
+// Enum is an integer-backed enumeration value whose Go type is selected by
+// the xsi:type attribute of its element, for polymorphic fields where the
+// same integer means different things depending on the declared type.
+type Enum struct {
+	Type  string      // xsi:type of the element, as written (e.g. "ns:OrderStatus")
+	Value interface{} // Value converted by the registered enum, or a plain int
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (v *Enum) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v.Type = ""
+	for _, a := range start.Attr {
+		if a.Name.Local == "type" && (a.Name.Space == XSINamespace || a.Name.Space == "xsi") {
+			v.Type = a.Value
+		}
+	}
+	var s string
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	local := v.Type
+	if i := strings.LastIndex(local, ":"); i >= 0 {
+		local = local[i+1:]
+	}
+	enumMu.RLock()
+	conv, ok := enumTypes[local]
+	enumMu.RUnlock()
+	if ok {
+		v.Value = conv(n)
+	} else {
+		v.Value = n
+	}
+	return nil
+}
+
+// MarshalXML implements the xml.Marshaler interface. The xsi prefix is
+// declared on the envelope. An Enum without Value is omitted, and one whose
+// Value is not of an integer kind fails to encode.
+func (v Enum) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if v.Value == nil {
+		return nil
+	}
+	var s string
+	switch rv := reflect.ValueOf(v.Value); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(rv.Uint(), 10)
+	default:
+		return fmt.Errorf("soap: Enum value %v of type %T is not an integer", v.Value, v.Value)
+	}
+	if v.Type != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: v.Type})
+	}
+	return e.EncodeElement(s, start)
+}
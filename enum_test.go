@@ -0,0 +1,37 @@
+package soap
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+type orderStatus int
+
+func TestEnumMarshal(t *testing.T) {
+	type order struct {
+		XMLName xml.Name `xml:"Order"`
+		Status  Enum     `xml:"Status"`
+	}
+	tests := []struct {
+		value Enum
+		want  string
+	}{
+		{Enum{}, `<Order></Order>`},
+		{Enum{Value: 3}, `<Order><Status>3</Status></Order>`},
+		{Enum{Type: "ns:OrderStatus", Value: orderStatus(2)}, `<Order><Status xsi:type="ns:OrderStatus">2</Status></Order>`},
+		{Enum{Value: uint8(7)}, `<Order><Status>7</Status></Order>`},
+	}
+	for _, tt := range tests {
+		b, err := xml.Marshal(order{Status: tt.value})
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", tt.value, err)
+			continue
+		}
+		if got := string(b); got != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+	if _, err := xml.Marshal(order{Status: Enum{Value: "three"}}); err == nil {
+		t.Error("Marshal of a string Enum value succeeded")
+	}
+}
@@ -0,0 +1,45 @@
+package soap
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// NewClientFromEnv returns a Client configured from environment variables,
+// for scripts and tools:
+//
+//   - SOAP_URL: URL of the server (required, http or https)
+//   - SOAP_NAMESPACE: ThisNamespace of the client
+//   - SOAP_TIMEOUT: overall timeout of a call, as parsed by
+//     time.ParseDuration (e.g. "30s")
+//   - HTTP_PROXY, HTTPS_PROXY and NO_PROXY: proxy settings, as read by
+//     http.ProxyFromEnvironment
+func NewClientFromEnv() (*Client, error) {
+	c := &Client{
+		URL:           os.Getenv("SOAP_URL"),
+		ThisNamespace: os.Getenv("SOAP_NAMESPACE"),
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("soap: SOAP_URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("soap: SOAP_URL %q is not an http or https URL", c.URL)
+	}
+	var timeout time.Duration
+	if s := os.Getenv("SOAP_TIMEOUT"); s != "" {
+		if timeout, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("soap: SOAP_TIMEOUT: %w", err)
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("soap: SOAP_TIMEOUT %q is not positive", s)
+		}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	c.Config = &http.Client{Transport: t, Timeout: timeout}
+	return c, nil
+}
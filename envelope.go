@@ -0,0 +1,247 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MarshalXML implements the xml.Marshaler interface.
+//
+// When several settings declare namespaces, they combine as follows:
+//
+//   - The Envelope element declares, in order, its prefix (Prefix,
+//     default soapenv) for EnvelopeAttr, then xsi (XSIAttr) when set, then
+//     the Namespaces in the order of their prefixes. A prefix is declared
+//     once; the first declaration wins.
+//   - The Header is encoded as its own value dictates, except that
+//     namespace declarations it puts on the Header element for a prefix
+//     already declared on the Envelope are dropped: the Envelope binding
+//     applies to the whole document, so a Header cannot rebind the
+//     envelope prefix.
+//   - BodyNSAttr is declared as the default namespace (xmlns) of the Body
+//     element, so unprefixed elements of the Body belong to it. Elements
+//     whose struct tags name a namespace still declare their own.
+func (env Envelope) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	prefix, attrs := env.declarations()
+	body := env.Body
+	if env.BodyNSAttr != "" && body != nil {
+		body = defaultNSElement{v: body, ns: env.BodyNSAttr}
+	}
+	envelope := xml.StartElement{Name: xml.Name{Local: prefix + ":Envelope"}, Attr: attrs.attrs}
+	if err := e.EncodeToken(envelope); err != nil {
+		return err
+	}
+	if err := env.encodeHeader(e, prefix, attrs.uris); err != nil {
+		return err
+	}
+	if body != nil {
+		if err := e.EncodeElement(body, xml.StartElement{Name: xml.Name{Local: prefix + ":Body"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(envelope.End())
+}
+
+// declarations returns the prefix of the elements of env and the namespace
+// declarations of its Envelope element.
+func (env Envelope) declarations() (string, *namespaces) {
+	prefix := env.Prefix
+	if prefix == "" {
+		prefix = "soapenv"
+	}
+	attrs := newNamespaces()
+	attrs.declare(prefix, env.EnvelopeAttr, true)
+	attrs.declare("xsi", env.XSIAttr, false)
+	prefixes := make([]string, 0, len(env.Namespaces))
+	for p := range env.Namespaces {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	for _, p := range prefixes {
+		attrs.declare(p, env.Namespaces[p], false)
+	}
+	return prefix, attrs
+}
+
+// encodeHeader encodes the Header element of env, if it has one, given the
+// prefix and namespaces declared on the Envelope element.
+func (env Envelope) encodeHeader(e *xml.Encoder, prefix string, declared map[string]string) error {
+	if env.Header == nil {
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: prefix + ":Header"}}
+	return e.EncodeElement(headerElement{h: env.Header, declared: declared}, start)
+}
+
+// defaultNSElement encodes v with a default namespace declaration on its
+// element.
+type defaultNSElement struct {
+	v  Message
+	ns string
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (el defaultNSElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: el.ns})
+	return e.EncodeElement(el.v, start)
+}
+
+// namespaces accumulates the namespace declarations of an element.
+type namespaces struct {
+	attrs []xml.Attr
+	uris  map[string]string // by prefix
+}
+
+func newNamespaces() *namespaces {
+	return &namespaces{uris: make(map[string]string)}
+}
+
+// declare adds a declaration of prefix for uri, unless the prefix is
+// already declared. An empty uri is declared only if always is set.
+func (ns *namespaces) declare(prefix, uri string, always bool) {
+	if uri == "" && !always {
+		return
+	}
+	if _, ok := ns.uris[prefix]; ok {
+		return
+	}
+	ns.uris[prefix] = uri
+	ns.attrs = append(ns.attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: uri})
+}
+
+// headerElement encodes the Header h, dropping the namespace declarations
+// it makes on the Header element for prefixes in declared.
+type headerElement struct {
+	h        Header
+	declared map[string]string
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (he headerElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	first := true
+	return replayElement(e, he.h, start, func(t *xml.StartElement) {
+		if !first {
+			return
+		}
+		first = false
+		attrs := t.Attr[:0]
+		for _, a := range t.Attr {
+			if prefix := strings.TrimPrefix(a.Name.Local, "xmlns:"); prefix != a.Name.Local {
+				if _, ok := he.declared[prefix]; ok {
+					continue
+				}
+			}
+			attrs = append(attrs, a)
+		}
+		t.Attr = attrs
+	})
+}
+
+// mergedHeader is a Header holding the blocks of several Headers, in order,
+// in a single Header element. The attributes the Headers put on their own
+// element, such as namespace declarations, are merged onto it; the first
+// one of a given name wins.
+type mergedHeader []Header
+
+// MarshalXML implements the xml.Marshaler interface.
+func (hs mergedHeader) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var blocks []xml.Token
+	seen := make(map[xml.Name]bool)
+	for _, a := range start.Attr {
+		seen[a.Name] = true
+	}
+	for _, h := range hs {
+		if h == nil {
+			continue
+		}
+		toks, err := literalTokens(h, start)
+		if err != nil {
+			return err
+		}
+		if len(toks) < 2 {
+			continue // nothing encoded
+		}
+		for _, a := range toks[0].(xml.StartElement).Attr {
+			if !seen[a.Name] {
+				seen[a.Name] = true
+				start.Attr = append(start.Attr, a)
+			}
+		}
+		blocks = append(blocks, toks[1:len(toks)-1]...)
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, tok := range blocks {
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// replayElement encodes v as the element start on its own, then replays the
+// result onto e token by token, passing every start element through edit.
+// Prefixed names are kept literally, as written by the original encoding.
+func replayElement(e *xml.Encoder, v interface{}, start xml.StartElement, edit func(*xml.StartElement)) error {
+	toks, err := literalTokens(v, start)
+	if err != nil {
+		return err
+	}
+	for _, tok := range toks {
+		if t, ok := tok.(xml.StartElement); ok {
+			edit(&t)
+			tok = t
+		}
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literalTokens encodes v as the element start on its own and returns the
+// resulting tokens, with prefixed names folded by literalName.
+func literalTokens(v interface{}, start xml.StartElement) ([]xml.Token, error) {
+	var b bytes.Buffer
+	if err := xml.NewEncoder(&b).EncodeElement(v, start); err != nil {
+		return nil, err
+	}
+	var toks []xml.Token
+	d := xml.NewDecoder(&b)
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return toks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := xml.CopyToken(tok).(type) {
+		case xml.StartElement:
+			t.Name = literalName(t.Name)
+			for i := range t.Attr {
+				t.Attr[i].Name = literalName(t.Attr[i].Name)
+			}
+			tok = t
+		case xml.EndElement:
+			t.Name = literalName(t.Name)
+			tok = t
+		default:
+			tok = t
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// literalName folds the prefix of a raw name into its local part, so that
+// the encoder writes it back unchanged.
+func literalName(n xml.Name) xml.Name {
+	if n.Space == "" {
+		return n
+	}
+	return xml.Name{Local: n.Space + ":" + n.Local}
+}
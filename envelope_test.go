@@ -0,0 +1,88 @@
+package soap
+
+import "testing"
+
+// city is the content of a Body using a prefix declared by the Client.
+type city struct {
+	Name string `xml:"tns:City"`
+}
+
+func TestEnvelopeNamespaces(t *testing.T) {
+	auth := AuthHeader{Namespace: "urn:auth", Username: "u", Password: "p"}
+	tests := []struct {
+		name   string
+		client *Client
+		want   string
+	}{
+		{
+			name:   "defaults",
+			client: &Client{},
+			want: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+				`<soapenv:Body><tns:City>Paris</tns:City></soapenv:Body></soapenv:Envelope>`,
+		},
+		{
+			name:   "EnvelopePrefix",
+			client: &Client{EnvelopePrefix: "s"},
+			want: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+				`<s:Body><tns:City>Paris</tns:City></s:Body></s:Envelope>`,
+		},
+		{
+			name: "ExtraNamespaces under Namespaces",
+			client: &Client{
+				Namespaces:      map[string]string{"tns": "urn:tns"},
+				ExtraNamespaces: map[string]string{"tns": "urn:extra", "x": "urn:x"},
+			},
+			want: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:tns="urn:tns" xmlns:x="urn:x">` +
+				`<soapenv:Body><tns:City>Paris</tns:City></soapenv:Body></soapenv:Envelope>`,
+		},
+		{
+			name:   "Header rebinding the envelope prefix",
+			client: &Client{Header: auth},
+			want: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+				`<soapenv:Header><ns:username>u</ns:username><ns:password>p</ns:password></soapenv:Header>` +
+				`<soapenv:Body><tns:City>Paris</tns:City></soapenv:Body></soapenv:Envelope>`,
+		},
+		{
+			name:   "Header and EnvelopePrefix",
+			client: &Client{Header: auth, EnvelopePrefix: "s"},
+			want: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+				`<s:Header xmlns:soapenv="urn:auth"><ns:username>u</ns:username><ns:password>p</ns:password></s:Header>` +
+				`<s:Body><tns:City>Paris</tns:City></s:Body></s:Envelope>`,
+		},
+		{
+			name:   "Header and ExtraNamespaces",
+			client: &Client{Header: auth, ExtraNamespaces: map[string]string{"tns": "urn:tns"}},
+			want: `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:tns="urn:tns">` +
+				`<soapenv:Header><ns:username>u</ns:username><ns:password>p</ns:password></soapenv:Header>` +
+				`<soapenv:Body><tns:City>Paris</tns:City></soapenv:Body></soapenv:Envelope>`,
+		},
+		{
+			name: "Header, ExtraNamespaces and EnvelopePrefix",
+			client: &Client{
+				Header:          auth,
+				EnvelopePrefix:  "s",
+				ExtraNamespaces: map[string]string{"soapenv": "urn:extra", "tns": "urn:tns"},
+			},
+			want: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:soapenv="urn:extra" xmlns:tns="urn:tns">` +
+				`<s:Header><ns:username>u</ns:username><ns:password>p</ns:password></s:Header>` +
+				`<s:Body><tns:City>Paris</tns:City></s:Body></s:Envelope>`,
+		},
+		{
+			name:   "ExtraNamespaces cannot rebind the envelope prefix",
+			client: &Client{EnvelopePrefix: "s", ExtraNamespaces: map[string]string{"s": "urn:extra"}},
+			want: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+				`<s:Body><tns:City>Paris</tns:City></s:Body></s:Envelope>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.client.encode(tt.client.envelope(newCallOptions(nil), "", &city{Name: "Paris"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package soap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serve returns a server answering every request with the given status,
+// Content-Type and body.
+func serve(status int, contentType, body string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	return srv
+}
+
+func TestTransportErrorDeadline(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	c := &Client{URL: srv.URL}
+	err := c.RoundTripWith(&ping{}, &ping{}, WithTimeout(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is(%v, context.DeadlineExceeded) = false", err)
+	}
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || !transportErr.Aborted {
+		t.Errorf("got error %v, want an aborted *TransportError", err)
+	}
+}
+
+func TestTransportErrorNet(t *testing.T) {
+	srv := serve(http.StatusOK, "text/xml", pongEnvelope)
+	srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.RoundTrip(&ping{}, &ping{})
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || transportErr.Aborted {
+		t.Errorf("got error %v, want a *TransportError", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("errors.As(%v, *net.OpError) = false", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	srv := serve(http.StatusOK, "text/xml", "<soapenv:Envelope")
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.RoundTrip(&ping{}, &ping{})
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("got error %v, want a *DecodeError", err)
+	}
+	var syntaxErr *xml.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("errors.As(%v, *xml.SyntaxError) = false", err)
+	}
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		t.Errorf("decode error %v is a *TransportError", err)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	srv := serve(http.StatusNotFound, "text/plain", "not found")
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.RoundTrip(&ping{}, &ping{})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("got error %v, want a 404 *HTTPError", err)
+	}
+	if httpErr.Fault != nil {
+		t.Errorf("Fault = %v, want nil", httpErr.Fault)
+	}
+	var fault *Fault
+	if errors.As(err, &fault) {
+		t.Errorf("errors.As(%v, *Fault) = true", err)
+	}
+}
+
+func TestHTTPErrorFault(t *testing.T) {
+	srv := serve(http.StatusInternalServerError, "text/xml", `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body>`+
+		`<soapenv:Fault><faultcode>soapenv:Server</faultcode><faultstring>boom</faultstring></soapenv:Fault>`+
+		`</soapenv:Body></soapenv:Envelope>`)
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.RoundTrip(&ping{}, &ping{})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got error %v, want a 500 *HTTPError", err)
+	}
+	var fault *Fault
+	if !errors.As(err, &fault) || fault.FaultString != "boom" {
+		t.Fatalf("errors.As(%v, *Fault) found %v", err, fault)
+	}
+	if fault != httpErr.Fault {
+		t.Error("HTTPError.Unwrap does not return HTTPError.Fault")
+	}
+}
+
+// truncated returns a server declaring a longer body than it sends before
+// closing the connection.
+func truncated(contentType, encoding string, body []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)+1000))
+		w.Write(body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+}
+
+func TestTransportErrorReadingResponse(t *testing.T) {
+	body := []byte(`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><Ping>`)
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write(body)
+	zw.Flush()
+	tests := []struct {
+		name   string
+		srv    *httptest.Server
+		client func(*Client)
+	}{
+		{"streamed", truncated("text/xml", "", body), func(*Client) {}},
+		{"Debug", truncated("text/xml", "", body), func(c *Client) { c.Debug, c.Logf = true, func(string, ...interface{}) {} }},
+		{"OnResponseBody", truncated("text/xml", "", body), func(c *Client) { c.OnResponseBody = func([]byte) {} }},
+		{"gzip", truncated("text/xml", "gzip", gzipped.Bytes()), func(c *Client) { c.Compress = true }},
+		{"sniffed gzip", truncated("text/xml", "", gzipped.Bytes()), func(c *Client) { c.SniffCompression = true }},
+	}
+	for _, tt := range tests {
+		c := &Client{URL: tt.srv.URL}
+		tt.client(c)
+		err := c.RoundTrip(&ping{}, &ping{})
+		tt.srv.Close()
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) || !transportErr.Response {
+			t.Errorf("%s: got error %v, want a *TransportError reading the response", tt.name, err)
+		}
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			t.Errorf("%s: transport error %v is a *DecodeError", tt.name, err)
+		}
+	}
+
+	srv := truncated("text/xml", "", body)
+	defer srv.Close()
+	c := &Client{URL: srv.URL}
+	_, _, _, err := c.SendEnvelope(context.Background(), c.envelope(newCallOptions(nil), "", &ping{}))
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Errorf("SendEnvelope: got error %v, want a *TransportError", err)
+	}
+	bus := &BusClient{BaseURL: srv.URL}
+	_, err = bus.RoundTripWithBus("", []byte(`{}`))
+	if !errors.As(err, &transportErr) {
+		t.Errorf("RoundTripWithBus: got error %v, want a *TransportError", err)
+	}
+}
+
+func TestDecodeErrorGzip(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		client   func(*Client)
+	}{
+		{"Content-Encoding", "gzip", func(c *Client) { c.Compress = true }},
+		{"sniffed", "", func(c *Client) { c.SniffCompression = true }},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/xml")
+			if tt.encoding != "" {
+				w.Header().Set("Content-Encoding", tt.encoding)
+			}
+			w.Write([]byte("\x1f\x8bnot gzip at all"))
+		}))
+		c := &Client{URL: srv.URL}
+		tt.client(c)
+		err := c.RoundTrip(&ping{}, &ping{})
+		srv.Close()
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: got error %v, want a *DecodeError", tt.name, err)
+		}
+		var transportErr *TransportError
+		if errors.As(err, &transportErr) {
+			t.Errorf("%s: decode error %v is a *TransportError", tt.name, err)
+		}
+	}
+}
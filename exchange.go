@@ -0,0 +1,114 @@
+package soap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// doer executes HTTP requests, as *http.Client does.
+type doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// exchange holds the transport settings shared by Client and BusClient, so
+// that both send their requests the same way.
+type exchange struct {
+	doer   doer
+	pre    func(*http.Request)  // hook run on every attempt before sending
+	post   func(*http.Response) // hook run on the final response
+	retry  *RetryPolicy         // nil for a single attempt
+	budget *RetryBudget
+	check  func() error // optional check run after every attempt, failing the call
+
+	onRetry func(attempt int, delay time.Duration, err error) // optional hook run before every retry
+}
+
+// do posts the body returned by newBody to url, calling newBody again for
+// every attempt, and retries according to the retry policy. The caller must
+// close the response body.
+func (x *exchange) do(ctx context.Context, url string, newBody func() io.Reader, setHeaders func(*http.Request)) (*http.Response, error) {
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		r, err := http.NewRequestWithContext(ctx, "POST", url, newBody())
+		if err != nil {
+			return nil, fmt.Errorf("soap: creating request: %w", err)
+		}
+		setHeaders(r)
+		if x.pre != nil {
+			x.pre(r)
+		}
+		resp, err = x.doer.Do(r)
+		if x.check != nil {
+			if err := x.check(); err != nil {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				return nil, err
+			}
+		}
+		if !x.shouldRetry(ctx, attempt, resp, err) {
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, &TransportError{URL: url, Aborted: true, Err: ctx.Err()}
+				}
+				return nil, &TransportError{URL: url, Err: err}
+			}
+			break
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		delay := x.retry.delay(attempt)
+		after, ok := retryAfter(resp)
+		if ok {
+			delay = x.retry.retryAfterDelay(after)
+		}
+		if x.onRetry != nil {
+			if err == nil {
+				err = &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: after}
+			}
+			x.onRetry(attempt, delay, err)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, &TransportError{URL: url, Aborted: true, Err: err}
+		}
+	}
+	if x.post != nil {
+		x.post(resp)
+	}
+	return resp, nil
+}
+
+// transportReader is an io.Reader returning the errors of r, reading the
+// body of a response from the network, as *TransportError.
+type transportReader struct {
+	ctx context.Context
+	r   io.Reader
+	url string
+}
+
+// newTransportReader returns a transportReader reading the body of resp
+// from r.
+func newTransportReader(ctx context.Context, resp *http.Response, r io.Reader) *transportReader {
+	tr := &transportReader{ctx: ctx, r: r}
+	if resp.Request != nil {
+		tr.url = resp.Request.URL.String()
+	}
+	return tr
+}
+
+func (tr *transportReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if err != nil && err != io.EOF {
+		if tr.ctx.Err() != nil {
+			return n, &TransportError{URL: tr.url, Aborted: true, Response: true, Err: tr.ctx.Err()}
+		}
+		return n, &TransportError{URL: tr.url, Response: true, Err: err}
+	}
+	return n, err
+}
@@ -0,0 +1,175 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Fault is a SOAP fault returned by the server in the response Body. Both
+// SOAP 1.1 (faultcode/faultstring) and SOAP 1.2 (Code/Reason) layouts are
+// decoded onto the same fields.
+type Fault struct {
+	XMLName     xml.Name     `xml:"Fault"`
+	FaultCode   string       `xml:"faultcode"`
+	FaultString string       `xml:"faultstring"`
+	FaultActor  string       `xml:"faultactor,omitempty"`
+	Detail      *FaultDetail `xml:"detail,omitempty"`
+
+	header []xml.Token // SOAP Header of the response carrying the fault
+}
+
+// FaultDetail carries the application specific error information of a
+// Fault, both as raw XML and split into its top-level entries.
+type FaultDetail struct {
+	Content []byte        `xml:",innerxml"`
+	Entries []DetailEntry `xml:",any"`
+}
+
+// DetailEntry is a single child element of a fault detail, such as one of
+// several validation errors reported together.
+type DetailEntry struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content []byte     `xml:",innerxml"`
+}
+
+// Details returns the entries of the fault detail, if any.
+func (f *Fault) Details() []DetailEntry {
+	if f.Detail == nil {
+		return nil
+	}
+	return f.Detail.Entries
+}
+
+// Error implements the error interface.
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%q: %q", f.FaultCode, f.FaultString)
+}
+
+// UnmarshalXML decodes a SOAP 1.1 or SOAP 1.2 fault element.
+func (f *Fault) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Code     string       `xml:"faultcode"`
+		String   string       `xml:"faultstring"`
+		Actor    string       `xml:"faultactor"`
+		Detail   *FaultDetail `xml:"detail"`
+		Code12   string       `xml:"Code>Value"`
+		Reason12 string       `xml:"Reason>Text"`
+		Role12   string       `xml:"Role"`
+		Detail12 *FaultDetail `xml:"Detail"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	f.XMLName = start.Name
+	f.FaultCode = strings.TrimSpace(firstNonEmpty(v.Code, v.Code12))
+	f.FaultString = strings.TrimSpace(firstNonEmpty(v.String, v.Reason12))
+	f.FaultActor = strings.TrimSpace(firstNonEmpty(v.Actor, v.Role12))
+	f.Detail = v.Detail
+	if f.Detail == nil {
+		f.Detail = v.Detail12
+	}
+	return nil
+}
+
+// IsMustUnderstand reports whether the fault code is MustUnderstand, i.e.
+// the server did not understand a header block marked mustUnderstand.
+func (f *Fault) IsMustUnderstand() bool {
+	code := f.FaultCode
+	if i := strings.LastIndex(code, ":"); i >= 0 {
+		code = code[i+1:]
+	}
+	return code == "MustUnderstand"
+}
+
+// MustUnderstandFault is returned instead of a plain Fault when the server
+// reports one or more mandatory request header blocks it does not
+// understand. Headers lists the qualified names of those blocks, taken from
+// the NotUnderstood entries of the response Header or the fault detail.
+type MustUnderstandFault struct {
+	*Fault
+	Headers []xml.Name
+}
+
+// Unwrap returns the underlying Fault.
+func (e *MustUnderstandFault) Unwrap() error {
+	return e.Fault
+}
+
+// faultError returns the error to report for f, given the tokens of the
+// response Header element.
+func faultError(f *Fault, header []xml.Token) error {
+	f.header = header
+	if !f.IsMustUnderstand() {
+		return f
+	}
+	names := notUnderstood(header)
+	if f.Detail != nil {
+		var toks []xml.Token
+		d := xml.NewDecoder(bytes.NewReader(f.Detail.Content))
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				break
+			}
+			toks = append(toks, xml.CopyToken(tok))
+		}
+		names = append(names, notUnderstood(toks)...)
+	}
+	return &MustUnderstandFault{Fault: f, Headers: names}
+}
+
+// notUnderstood extracts the qname attribute of every NotUnderstood element
+// in toks. The prefix of the qname is resolved against the namespace
+// declarations of the element itself.
+func notUnderstood(toks []xml.Token) []xml.Name {
+	var names []xml.Name
+	for _, tok := range toks {
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "NotUnderstood" {
+			continue
+		}
+		var qname string
+		for _, a := range start.Attr {
+			if a.Name.Local == "qname" {
+				qname = a.Value
+			}
+		}
+		if qname == "" {
+			continue
+		}
+		name := xml.Name{Local: qname}
+		if i := strings.Index(qname, ":"); i >= 0 {
+			prefix := qname[:i]
+			name = xml.Name{Space: prefix, Local: qname[i+1:]}
+			for _, a := range start.Attr {
+				if a.Name.Space == "xmlns" && a.Name.Local == prefix {
+					name.Space = a.Value
+				}
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// DecodeHeader decodes the SOAP Header of the response carrying the fault
+// onto v, whose fields match the header blocks as with WithResponseHeader.
+// If the response had no Header, v is left untouched.
+func (f *Fault) DecodeHeader(v interface{}) error {
+	if f.header == nil {
+		return nil
+	}
+	return decodeTokens(f.header, v)
+}
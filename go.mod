@@ -1,3 +1,3 @@
 module github.com/lcplj123/soap
 
-go 1.12
+go 1.13
@@ -0,0 +1,44 @@
+package soap
+
+import (
+	"context"
+	"net/http"
+)
+
+// Call describes a call going through the middleware of a Client.
+type Call struct {
+	Action string  // SOAP action of the call, for information: changing it has no effect
+	In     Message // request message, which a middleware may replace
+	Out    Message // destination of the response, which a middleware may replace
+
+	// Header holds HTTP headers set on the requests of the call, replacing
+	// those set by the Client, as with WithHeader.
+	Header http.Header
+
+	// Response describes the HTTP response once the call has returned, if
+	// one was received.
+	Response *Response
+}
+
+// RoundTripFunc performs a call.
+type RoundTripFunc func(ctx context.Context, call *Call) error
+
+// Middleware wraps the round trip of a call, running code before and after
+// next, or instead of it.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use adds middleware wrapping the round trips of the Client, from the
+// RoundTrip, RoundTripWithAction and RoundTripSoap12 families. The first
+// middleware added is the outermost one. Use must not be called
+// concurrently with calls.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// withMiddleware returns rt wrapped by the middleware of the Client.
+func (c *Client) withMiddleware(rt RoundTripFunc) RoundTripFunc {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	return rt
+}
@@ -0,0 +1,123 @@
+package soap
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// MockHandler answers a call made to a MockClient with the response
+// message to copy into out, or an error to return, such as a *Fault.
+type MockHandler func(in Message) (out Message, err error)
+
+// MockClient is a RoundTripper answering calls with registered handlers
+// instead of a server, for testing code that consumes SOAP services. Calls
+// are matched by action, for RoundTripSoap12, then by the type name of the
+// request message, as the Client derives actions by default. It is safe
+// for concurrent use.
+type MockClient struct {
+	mu       sync.Mutex
+	handlers map[string]MockHandler
+	calls    []string
+}
+
+// NewMockClient returns a MockClient without handlers.
+func NewMockClient() *MockClient {
+	return &MockClient{handlers: make(map[string]MockHandler)}
+}
+
+// On registers the handler of the calls with the given action or request
+// type name, replacing any previous one.
+func (m *MockClient) On(key string, handler MockHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[key] = handler
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (m *MockClient) RoundTrip(in, out Message) error {
+	return m.call("", in, out)
+}
+
+// RoundTripSoap12 implements the RoundTripper interface.
+func (m *MockClient) RoundTripSoap12(action string, in, out Message) error {
+	return m.call(action, in, out)
+}
+
+// Calls returns the keys of the calls made so far, in order: the key the
+// handler was registered with, or the type name of the request if none was.
+func (m *MockClient) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+// Called returns the number of calls made so far with the given key.
+func (m *MockClient) Called(key string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, call := range m.calls {
+		if call == key {
+			n++
+		}
+	}
+	return n
+}
+
+// call answers a call with the handler of action, or else of the type
+// name of in.
+func (m *MockClient) call(action string, in, out Message) error {
+	name := typeName(in)
+	m.mu.Lock()
+	key := action
+	handler, ok := m.handlers[action]
+	if !ok || action == "" {
+		key = name
+		handler, ok = m.handlers[name]
+	}
+	m.calls = append(m.calls, key)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("soap: no mock handler for action %q or request type %q", action, name)
+	}
+	resp, err := handler(in)
+	if err != nil {
+		return err
+	}
+	return copyMessage(out, resp)
+}
+
+// typeName returns the name of the type of in, pointers dereferenced.
+func typeName(in Message) string {
+	t := reflect.TypeOf(in)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
+
+// copyMessage stores the value of resp, or of what it points to, in the
+// value out points to.
+func copyMessage(out, resp Message) error {
+	if out == nil || resp == nil {
+		return nil
+	}
+	dst := reflect.ValueOf(out)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return fmt.Errorf("soap: mock response destination %T is not a non-nil pointer", out)
+	}
+	dst = dst.Elem()
+	src := reflect.ValueOf(resp)
+	for !src.Type().AssignableTo(dst.Type()) && src.Kind() == reflect.Ptr && !src.IsNil() {
+		src = src.Elem()
+	}
+	if !src.Type().AssignableTo(dst.Type()) {
+		return fmt.Errorf("soap: mock response %T cannot be stored in %T", resp, out)
+	}
+	dst.Set(src)
+	return nil
+}
@@ -0,0 +1,130 @@
+package soap
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type getQuote struct {
+	Symbol string
+}
+
+type getQuoteResponse struct {
+	Price float64
+}
+
+func TestMockClient(t *testing.T) {
+	quote := func(in Message) (Message, error) {
+		return &getQuoteResponse{Price: 42}, nil
+	}
+	tests := []struct {
+		name      string
+		handlers  map[string]MockHandler
+		action    string // RoundTripSoap12 if set
+		out       Message
+		want      Message
+		wantErr   string // substring of the error, "" for none
+		wantCalls []string
+	}{
+		{
+			name:      "request type name",
+			handlers:  map[string]MockHandler{"getQuote": quote},
+			out:       &getQuoteResponse{},
+			want:      &getQuoteResponse{Price: 42},
+			wantCalls: []string{"getQuote"},
+		},
+		{
+			name:      "action",
+			handlers:  map[string]MockHandler{"urn:GetQuote": quote},
+			action:    "urn:GetQuote",
+			out:       &getQuoteResponse{},
+			want:      &getQuoteResponse{Price: 42},
+			wantCalls: []string{"urn:GetQuote"},
+		},
+		{
+			name:      "unknown action falls back to the type name",
+			handlers:  map[string]MockHandler{"getQuote": quote},
+			action:    "urn:Other",
+			out:       &getQuoteResponse{},
+			want:      &getQuoteResponse{Price: 42},
+			wantCalls: []string{"getQuote"},
+		},
+		{
+			name: "value response",
+			handlers: map[string]MockHandler{"getQuote": func(in Message) (Message, error) {
+				return getQuoteResponse{Price: 7}, nil
+			}},
+			out:       &getQuoteResponse{},
+			want:      &getQuoteResponse{Price: 7},
+			wantCalls: []string{"getQuote"},
+		},
+		{
+			name:      "response of another type",
+			handlers:  map[string]MockHandler{"getQuote": quote},
+			out:       &getQuote{},
+			want:      &getQuote{},
+			wantErr:   "cannot be stored",
+			wantCalls: []string{"getQuote"},
+		},
+		{
+			name:      "expectation not met",
+			handlers:  map[string]MockHandler{"getPrice": quote},
+			action:    "urn:GetPrice",
+			out:       &getQuoteResponse{},
+			want:      &getQuoteResponse{},
+			wantErr:   `no mock handler for action "urn:GetPrice" or request type "getQuote"`,
+			wantCalls: []string{"getQuote"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockClient()
+			for key, handler := range tt.handlers {
+				m.On(key, handler)
+			}
+			in := &getQuote{Symbol: "ACME"}
+			var err error
+			if tt.action != "" {
+				err = m.RoundTripSoap12(tt.action, in, tt.out)
+			} else {
+				err = m.RoundTrip(in, tt.out)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.out, tt.want) {
+				t.Errorf("out = %+v, want %+v", tt.out, tt.want)
+			}
+			if calls := m.Calls(); !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Calls() = %q, want %q", calls, tt.wantCalls)
+			}
+			for key := range tt.handlers {
+				want := 0
+				for _, call := range tt.wantCalls {
+					if call == key {
+						want++
+					}
+				}
+				if n := m.Called(key); n != want {
+					t.Errorf("Called(%q) = %d, want %d", key, n, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMockClientFault(t *testing.T) {
+	fault := &Fault{FaultCode: "soap:Server", FaultString: "unavailable"}
+	m := NewMockClient()
+	m.On("getQuote", func(in Message) (Message, error) { return nil, fault })
+	err := m.RoundTrip(&getQuote{}, &getQuoteResponse{})
+	var got *Fault
+	if !errors.As(err, &got) || got != fault {
+		t.Errorf("got error %v, want the handler's *Fault", err)
+	}
+}
@@ -0,0 +1,131 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+)
+
+// XOPNamespace is the namespace of XOP include elements.
+const XOPNamespace = "http://www.w3.org/2004/08/xop/include"
+
+// mtomRootID is the Content-ID of the envelope part of MTOM requests.
+const mtomRootID = "<root.message@soap>"
+
+// Attachment is a binary part of an MTOM request.
+type Attachment struct {
+	ContentID   string // identifier referenced by XOPInclude, without angle brackets
+	ContentType string // media type of Data (default application/octet-stream)
+	Data        []byte
+}
+
+// XOPInclude is a request field holding an attachment by reference: it is
+// encoded as an element containing an xop:Include of the attachment with
+// the given ContentID.
+type XOPInclude struct {
+	ContentID string
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (x XOPInclude) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	include := xml.StartElement{
+		Name: xml.Name{Local: "xop:Include"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns:xop"}, Value: XOPNamespace},
+			{Name: xml.Name{Local: "href"}, Value: "cid:" + x.ContentID},
+		},
+	}
+	if err := e.EncodeToken(include); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(include.End()); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// RoundTripMTOM is like RoundTrip, sending the envelope as the root part
+// of a multipart/related MTOM request followed by the attachments, which
+// the request message refers to with XOPInclude fields.
+func (c *Client) RoundTripMTOM(in, out Message, attachments []Attachment) error {
+	return c.RoundTripMTOMContext(context.Background(), in, out, attachments)
+}
+
+// RoundTripMTOMContext is like RoundTripMTOM, with a context bounding the
+// call.
+func (c *Client) RoundTripMTOMContext(ctx context.Context, in, out Message, attachments []Attachment) error {
+	o := newCallOptions(nil)
+	action := c.actionName(in)
+	return c.doCall(ctx, o, action, func(ctx context.Context, rec *callRecord) error {
+		b, err := c.encode(c.envelope(o, action, in))
+		if err != nil {
+			return err
+		}
+		if err := c.checkSize(b); err != nil {
+			return err
+		}
+		c.sent(ctx, b)
+		body, ct, err := mtomBody(b, attachments)
+		if err != nil {
+			return err
+		}
+		headerFunc := func(r *http.Request) {
+			c.setActionHeaders(r, ct, action, in != nil)
+		}
+		resp, err := c.post(ctx, o, action, headerFunc, true, func() io.Reader {
+			return bytes.NewReader(body)
+		})
+		if err != nil {
+			return err
+		}
+		rec.status, rec.closed = resp.StatusCode, resp.Close
+		return c.receive(ctx, o, resp, out)
+	})
+}
+
+// mtomBody returns the multipart/related body holding the envelope env and
+// the attachments, and its content type.
+func mtomBody(env []byte, attachments []Attachment) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	root := textproto.MIMEHeader{}
+	root.Set("Content-Type", `application/xop+xml; charset=utf-8; type="text/xml"`)
+	root.Set("Content-Transfer-Encoding", "8bit")
+	root.Set("Content-ID", mtomRootID)
+	w, err := mw.CreatePart(root)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write(env); err != nil {
+		return nil, "", err
+	}
+	for _, a := range attachments {
+		ct := a.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", ct)
+		h.Set("Content-Transfer-Encoding", "binary")
+		h.Set("Content-ID", "<"+a.ContentID+">")
+		if w, err = mw.CreatePart(h); err != nil {
+			return nil, "", err
+		}
+		if _, err := w.Write(a.Data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	ct := fmt.Sprintf(`multipart/related; type="application/xop+xml"; start="%s"; start-info="text/xml"; boundary="%s"`, mtomRootID, mw.Boundary())
+	return buf.Bytes(), ct, nil
+}
@@ -0,0 +1,198 @@
+package soap
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// SOAP12EncodingNamespace is the namespace of the SOAP 1.2 encoding
+// attributes, such as enc:ref and enc:id.
+const SOAP12EncodingNamespace = "http://www.w3.org/2003/05/soap-encoding"
+
+// refTarget is an element carrying an id attribute, referenced elsewhere.
+type refTarget struct {
+	attr    []xml.Attr // attributes other than the id
+	content []xml.Token
+}
+
+// refFrame is a sequence of tokens being replayed by refTokens, id naming
+// the referenced element it expands.
+type refFrame struct {
+	toks []xml.Token
+	id   string
+}
+
+// refTokens is an xml.TokenReader over the recorded content of the Body in
+// which every element referring to another with href="#id" (SOAP 1.1) or
+// enc:ref="id" (SOAP 1.2) is given the attributes and content of that
+// element, as multiref encoding expects. The referenced Body children are
+// left out. Circular references are left unresolved.
+type refTokens struct {
+	targets    map[string]refTarget
+	referenced map[string]bool
+	stack      []refFrame
+	depth      int // depth in the Body content, outside of expansions
+}
+
+// newRefTokens returns a refTokens over toks.
+func newRefTokens(toks []xml.Token) *refTokens {
+	r := &refTokens{
+		targets:    make(map[string]refTarget),
+		referenced: make(map[string]bool),
+		stack:      []refFrame{{toks: toks}},
+	}
+	type open struct {
+		id    string
+		start int
+	}
+	var opened []open
+	for i, tok := range toks {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			id, attr := elementID(t.Attr)
+			opened = append(opened, open{id, i})
+			if id != "" {
+				r.targets[id] = refTarget{attr: attr}
+			}
+			if ref := elementRef(t.Attr); ref != "" {
+				r.referenced[ref] = true
+			}
+		case xml.EndElement:
+			if len(opened) == 0 {
+				continue // the end of the Body
+			}
+			o := opened[len(opened)-1]
+			opened = opened[:len(opened)-1]
+			if o.id != "" {
+				target := r.targets[o.id]
+				target.content = toks[o.start+1 : i]
+				r.targets[o.id] = target
+			}
+		}
+	}
+	return r
+}
+
+// Token implements the xml.TokenReader interface.
+func (r *refTokens) Token() (xml.Token, error) {
+	for len(r.stack) > 0 {
+		f := &r.stack[len(r.stack)-1]
+		if len(f.toks) == 0 {
+			r.stack = r.stack[:len(r.stack)-1]
+			continue
+		}
+		tok := f.toks[0]
+		f.toks = f.toks[1:]
+		base := len(r.stack) == 1
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if base && r.depth == 0 {
+				if id, _ := elementID(t.Attr); id != "" && r.referenced[id] {
+					f.toks = skipElement(f.toks)
+					continue
+				}
+			}
+			ref := elementRef(t.Attr)
+			target, ok := r.targets[ref]
+			if !ok || r.expanding(ref) {
+				if base {
+					r.depth++
+				}
+				return tok, nil
+			}
+			t.Attr = mergeAttrs(t.Attr, target.attr)
+			rest := skipElement(f.toks)
+			end := f.toks[len(f.toks)-len(rest)-1] // the end element of t
+			f.toks = rest
+			content := append(append([]xml.Token(nil), target.content...), end)
+			r.stack = append(r.stack, refFrame{toks: content, id: ref})
+			return t, nil
+		case xml.EndElement:
+			if base {
+				r.depth--
+			}
+		}
+		return tok, nil
+	}
+	return nil, io.EOF
+}
+
+// expanding reports whether the element with the given id is being
+// expanded already.
+func (r *refTokens) expanding(id string) bool {
+	for _, f := range r.stack {
+		if f.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// skipElement returns the tokens following the end of the element whose
+// start precedes toks.
+func skipElement(toks []xml.Token) []xml.Token {
+	depth := 0
+	for i, tok := range toks {
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return toks[i+1:]
+			}
+			depth--
+		}
+	}
+	return nil
+}
+
+// elementID returns the id of an element with the given attributes, if
+// any, and its other attributes.
+func elementID(attrs []xml.Attr) (string, []xml.Attr) {
+	for i, a := range attrs {
+		if a.Name.Local == "id" && (a.Name.Space == "" || a.Name.Space == SOAP12EncodingNamespace) {
+			rest := append(append([]xml.Attr(nil), attrs[:i]...), attrs[i+1:]...)
+			return a.Value, rest
+		}
+	}
+	return "", attrs
+}
+
+// elementRef returns the id referenced by an element with the given
+// attributes, if any.
+func elementRef(attrs []xml.Attr) string {
+	for _, a := range attrs {
+		switch {
+		case a.Name.Local == "href" && a.Name.Space == "" && strings.HasPrefix(a.Value, "#"):
+			return a.Value[1:]
+		case a.Name.Local == "ref" && a.Name.Space == SOAP12EncodingNamespace:
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// mergeAttrs returns the attributes of a referring element, its reference
+// removed, followed by those of the referenced element it lacks.
+func mergeAttrs(attrs, target []xml.Attr) []xml.Attr {
+	var merged []xml.Attr
+	for _, a := range attrs {
+		if elementRef([]xml.Attr{a}) == "" {
+			merged = append(merged, a)
+		}
+	}
+	for _, a := range target {
+		found := false
+		for _, m := range merged {
+			if m.Name == a.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, a)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,145 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// resolveRefs returns the content of the Body held by body as refTokens
+// yields it.
+func resolveRefs(t *testing.T, body string) string {
+	d := xml.NewDecoder(strings.NewReader(body))
+	start, err := firstChild(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var toks []xml.Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+	toks = toks[:len(toks)-1] // the end of the Body, appended as decode does
+	r := newRefTokens(append(toks, start.End()))
+
+	var b bytes.Buffer
+	enc := xml.NewEncoder(&b)
+	for {
+		tok, err := r.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if end, ok := tok.(xml.EndElement); ok && end.Name == start.Name {
+			break
+		}
+		if err := enc.EncodeToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestRefTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "href",
+			body: `<Body><getOrder><customer href="#c1"/></getOrder><multiRef id="c1"><name>Ann</name></multiRef></Body>`,
+			want: `<getOrder><customer><name>Ann</name></customer></getOrder>`,
+		},
+		{
+			name: "attributes merged",
+			body: `<Body><getOrder><customer kind="retail" href="#c1"/></getOrder><multiRef id="c1" kind="other" type="person"/></Body>`,
+			want: `<getOrder><customer kind="retail" type="person"></customer></getOrder>`,
+		},
+		{
+			name: "enc:ref",
+			body: `<Body xmlns:enc="http://www.w3.org/2003/05/soap-encoding"><getOrder><customer enc:ref="c1"/></getOrder><person enc:id="c1"><name>Ann</name></person></Body>`,
+			want: `<getOrder><customer><name>Ann</name></customer></getOrder>`,
+		},
+		{
+			name: "nested",
+			body: `<Body><getOrder><order href="#o1"/></getOrder>` +
+				`<multiRef id="o1"><customer href="#c1"/><item href="#i1"/></multiRef>` +
+				`<multiRef id="c1"><name>Ann</name></multiRef>` +
+				`<multiRef id="i1"><sku>42</sku></multiRef></Body>`,
+			want: `<getOrder><order><customer><name>Ann</name></customer><item><sku>42</sku></item></order></getOrder>`,
+		},
+		{
+			name: "shared",
+			body: `<Body><getOrder><billing href="#a1"/><shipping href="#a1"/></getOrder><multiRef id="a1"><city>Oslo</city></multiRef></Body>`,
+			want: `<getOrder><billing><city>Oslo</city></billing><shipping><city>Oslo</city></shipping></getOrder>`,
+		},
+		{
+			name: "target before its reference",
+			body: `<Body><multiRef id="c1"><name>Ann</name></multiRef><getOrder><customer href="#c1"/></getOrder></Body>`,
+			want: `<getOrder><customer><name>Ann</name></customer></getOrder>`,
+		},
+		{
+			name: "cycle",
+			body: `<Body><getList><head href="#n1"/></getList>` +
+				`<multiRef id="n1"><next href="#n2"/></multiRef>` +
+				`<multiRef id="n2"><next href="#n1"/></multiRef></Body>`,
+			want: `<getList><head><next><next href="#n1"></next></next></head></getList>`,
+		},
+		{
+			name: "self reference",
+			body: `<Body><getNode><node href="#n1"/></getNode><multiRef id="n1"><self href="#n1"/></multiRef></Body>`,
+			want: `<getNode><node><self href="#n1"></self></node></getNode>`,
+		},
+		{
+			name: "unresolved and unreferenced",
+			body: `<Body><getOrder><customer href="#missing"/></getOrder><extra id="x1"/></Body>`,
+			want: `<getOrder><customer href="#missing"></customer></getOrder><extra id="x1"></extra>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveRefs(t, tt.body); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiRefDecode(t *testing.T) {
+	response := `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body>` +
+		`<getOrderResponse><order href="#o1"/></getOrderResponse>` +
+		`<multiRef id="o1"><customer href="#c1"/><total>9</total></multiRef>` +
+		`<multiRef id="c1"><name>Ann</name></multiRef>` +
+		`</soapenv:Body></soapenv:Envelope>`
+	var out struct {
+		Response struct {
+			Order struct {
+				Customer struct {
+					Name string `xml:"name"`
+				} `xml:"customer"`
+				Total int `xml:"total"`
+			} `xml:"order"`
+		} `xml:"getOrderResponse"`
+	}
+	rd := &responseDecoder{multiRef: true}
+	if err := rd.decode(strings.NewReader(response), &out); err != nil {
+		t.Fatal(err)
+	}
+	if order := out.Response.Order; order.Customer.Name != "Ann" || order.Total != 9 {
+		t.Errorf("order = %+v, want customer Ann and total 9", order)
+	}
+}
@@ -0,0 +1,210 @@
+package soap
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A CallOption configures a single call made with RoundTripWith.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	elementName    xml.Name
+	httpClient     *http.Client
+	responseHeader Message
+	expected       xml.Name
+	bare           bool
+	version        SOAPVersion // protocol of the call, set by the method used
+	response       *Response
+	envelopeNS     string
+	metricLabel    string
+	charsetReader  CharsetReader
+	header         http.Header
+	action         string
+	timeout        time.Duration
+	contentType    string
+	faultDetail    Message
+}
+
+func newCallOptions(opts []CallOption) *callOptions {
+	o := new(callOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithElementName encodes the request message as a single element of the
+// given name inside the SOAP Body, letting one struct serve several
+// operations of the same shape. The name uses the syntax of an xml struct
+// tag: either "local" (possibly prefixed, as in "tns:GetQuote") or
+// "namespace-URL local".
+func WithElementName(name string) CallOption {
+	return func(o *callOptions) {
+		o.elementName = tagName(name)
+	}
+}
+
+// tagName parses a name in the syntax of an xml struct tag.
+func tagName(name string) xml.Name {
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		return xml.Name{Space: name[:i], Local: name[i+1:]}
+	}
+	return xml.Name{Local: name}
+}
+
+// WithHTTPClient sends this call with the given HTTP client instead of the
+// Client's Config.
+func WithHTTPClient(cli *http.Client) CallOption {
+	return func(o *callOptions) {
+		o.httpClient = cli
+	}
+}
+
+// WithResponseHeader decodes the SOAP Header of the response onto h, whose
+// fields match the header blocks as Client.Header does for requests. An
+// Addressing value receives the WS-Addressing properties of the response.
+func WithResponseHeader(h Message) CallOption {
+	return func(o *callOptions) {
+		o.responseHeader = h
+	}
+}
+
+// bodyElement encodes v as an element of the given name, nested in the
+// element being marshaled. If prefix is set, the element is written with
+// it, binding it to the namespace of name if there is one.
+type bodyElement struct {
+	name   xml.Name
+	prefix string
+	v      Message
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (b bodyElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	wrapper := xml.StartElement{Name: b.name}
+	if b.prefix != "" {
+		wrapper.Name = xml.Name{Local: b.prefix + ":" + b.name.Local}
+		if b.name.Space != "" {
+			wrapper.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns:" + b.prefix}, Value: b.name.Space}}
+		}
+	}
+	if err := e.EncodeElement(b.v, wrapper); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// WithExpectedResponse checks that the first element of the response Body
+// has the given name, failing the call with a *ResponseElementError
+// otherwise, to catch requests dispatched to the wrong operation. The name
+// uses the syntax of WithElementName; the namespace is checked only if
+// given. Faults are returned as usual.
+func WithExpectedResponse(name string) CallOption {
+	return func(o *callOptions) {
+		o.expected = tagName(name)
+	}
+}
+
+// WithBareResponse decodes the children of the response Body onto the
+// fields of out even if out names its own element with an XMLName field,
+// for responses whose result is spread over several Body children rather
+// than held in a single wrapper.
+func WithBareResponse() CallOption {
+	return func(o *callOptions) {
+		o.bare = true
+	}
+}
+
+// Response describes the HTTP response of a call.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	URL        *url.URL // URL of the last request, after redirects
+
+	ConnectionClosed bool // whether the server closed the connection after the response
+}
+
+// WithResponse stores the description of the HTTP response of the call in
+// *resp, whether it succeeds or fails with a SOAP or HTTP error.
+func WithResponse(resp *Response) CallOption {
+	return func(o *callOptions) {
+		o.response = resp
+	}
+}
+
+// WithEnvelopeNamespace sends this call with the given envelope namespace
+// instead of the Client's Envelope.
+func WithEnvelopeNamespace(uri string) CallOption {
+	return func(o *callOptions) {
+		o.envelopeNS = uri
+	}
+}
+
+// WithMetricLabel sets the Label of the CallStats of this call, overriding
+// Client.MetricLabel.
+func WithMetricLabel(label string) CallOption {
+	return func(o *callOptions) {
+		o.metricLabel = label
+	}
+}
+
+// WithCharsetReader decodes the response of this call with the given
+// converter instead of Client.CharsetReader, for an operation answering in
+// an encoding of its own.
+func WithCharsetReader(reader CharsetReader) CallOption {
+	return func(o *callOptions) {
+		o.charsetReader = reader
+	}
+}
+
+// WithHeader sets the HTTP header key to value on the requests of this
+// call, replacing any value set by the Client. It may be given several
+// times.
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+	}
+}
+
+// WithAction sends this call with the given SOAPAction instead of the one
+// derived from the request type.
+func WithAction(action string) CallOption {
+	return func(o *callOptions) {
+		o.action = action
+	}
+}
+
+// WithTimeout bounds this call, retries and response decoding included, by
+// the given duration instead of Client.Timeout.
+func WithTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithContentType sends this call with the given Content-Type instead of
+// the Client's ContentType. The action is still added to it for
+// ActionContentType.
+func WithContentType(ct string) CallOption {
+	return func(o *callOptions) {
+		o.contentType = ct
+	}
+}
+
+// WithFaultDetail decodes the detail element of a fault answered to this
+// call onto v, which receives its children as out receives those of the
+// Body. The fault is still returned as the error of the call.
+func WithFaultDetail(v Message) CallOption {
+	return func(o *callOptions) {
+		o.faultDetail = v
+	}
+}
@@ -0,0 +1,186 @@
+package soap
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how a call is retried when the HTTP request fails at
+// the transport level or the server answers 429, 502, 503 or 504. SOAP
+// faults are never retried. A Retry-After header sent along with 429 or 503
+// replaces the computed delay before the next attempt, up to MaxRetryAfter.
+type RetryPolicy struct {
+	MaxAttempts   int           // Total number of attempts, including the first
+	Backoff       time.Duration // Delay before the first retry, doubled for every further retry
+	MaxBackoff    time.Duration // Optional upper bound of the delay
+	MaxRetryAfter time.Duration // Optional upper bound of the delay requested by Retry-After (default MaxBackoff, if set)
+	Jitter        Jitter        // Optional randomization of the delay (default FullJitter)
+}
+
+// Jitter randomizes the delay base computed for the given retry, starting
+// at 1, so that clients failing together do not retry together.
+type Jitter func(retry int, base time.Duration) time.Duration
+
+// Jitter strategies.
+var (
+	// FullJitter waits a random time between zero and the delay.
+	FullJitter Jitter = func(retry int, base time.Duration) time.Duration {
+		return randDuration(base)
+	}
+	// EqualJitter waits half the delay plus a random time up to the other
+	// half.
+	EqualJitter Jitter = func(retry int, base time.Duration) time.Duration {
+		return base/2 + randDuration(base-base/2)
+	}
+	// NoJitter waits exactly the delay.
+	NoJitter Jitter = func(retry int, base time.Duration) time.Duration {
+		return base
+	}
+)
+
+// randDuration returns a random duration in [0, d].
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// delay returns the time to wait before the given retry, starting at 1.
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	jitter := p.Jitter
+	if jitter == nil {
+		jitter = FullJitter
+	}
+	return jitter(retry, p.backoff(retry))
+}
+
+// backoff returns the delay before the given retry, before jitter.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// retryAfterDelay returns the delay d requested by Retry-After, bounded by
+// MaxRetryAfter or else MaxBackoff.
+func (p *RetryPolicy) retryAfterDelay(d time.Duration) time.Duration {
+	max := p.MaxRetryAfter
+	if max <= 0 {
+		max = p.MaxBackoff
+	}
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a 429
+// or 503 response, given either in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := time.Until(t); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// RetryBudget is a token bucket bounding the rate of retries. A single
+// budget may be shared by every call of a Client, or by several Clients, so
+// that a broad outage does not multiply the load on the server: once the
+// budget is exhausted, failed calls return their error instead of retrying.
+type RetryBudget struct {
+	mu     sync.Mutex
+	max    float64
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRetryBudget returns a budget allowing bursts of up to max retries,
+// refilled at perSecond retries per second.
+func NewRetryBudget(max int, perSecond float64) *RetryBudget {
+	return &RetryBudget{
+		max:    float64(max),
+		rate:   perSecond,
+		tokens: float64(max),
+		last:   time.Now(),
+	}
+}
+
+// Allow withdraws a retry from the budget, reporting false if none is left.
+func (b *RetryBudget) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// shouldRetry reports whether the given attempt, which produced resp and
+// err, is to be followed by another one. A call whose ctx is done is not
+// retried.
+func (x *exchange) shouldRetry(ctx context.Context, attempt int, resp *http.Response, err error) bool {
+	if x.retry == nil || attempt >= x.retry.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return false
+		}
+	}
+	return x.budget == nil || x.budget.Allow()
+}
+
+// sleep waits for d, returning early with the context error if ctx is done
+// first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
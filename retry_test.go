@@ -0,0 +1,116 @@
+package soap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryStopsWhenContextDone(t *testing.T) {
+	done := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hanging.Close()
+	defer close(done)
+	unavailable := serve(http.StatusServiceUnavailable, "text/plain", "busy")
+	defer unavailable.Close()
+	refused := serve(http.StatusOK, "text/xml", pongEnvelope)
+	refused.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		retries int // OnRetry calls expected
+	}{
+		{"attempt aborted", hanging.URL, 0},
+		{"wait for a status retry aborted", unavailable.URL, 1},
+		{"wait for a transport retry aborted", refused.URL, 1},
+	}
+	for _, tt := range tests {
+		retries := 0
+		c := &Client{
+			URL:     tt.url,
+			Timeout: 50 * time.Millisecond,
+			Retry:   &RetryPolicy{MaxAttempts: 5, Backoff: time.Second, Jitter: NoJitter},
+			OnRetry: func(attempt int, delay time.Duration, err error) { retries++ },
+		}
+		err := c.RoundTrip(&ping{}, &ping{})
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) || !transportErr.Aborted {
+			t.Errorf("%s: got error %v, want an aborted *TransportError", tt.name, err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: errors.Is(%v, context.DeadlineExceeded) = false", tt.name, err)
+		}
+		if retries != tt.retries {
+			t.Errorf("%s: OnRetry called %d times, want %d", tt.name, retries, tt.retries)
+		}
+	}
+}
+
+func TestRetryAfterBounded(t *testing.T) {
+	seconds := func() string { return "3600" }
+	date := func() string { return time.Now().Add(time.Hour).UTC().Format(http.TimeFormat) }
+	tests := []struct {
+		name       string
+		retryAfter func() string
+		policy     RetryPolicy
+		want       time.Duration // 0 for the delay requested, about an hour
+	}{
+		{"seconds, MaxBackoff", seconds, RetryPolicy{MaxBackoff: 10 * time.Millisecond}, 10 * time.Millisecond},
+		{"seconds, MaxRetryAfter", seconds, RetryPolicy{MaxBackoff: 10 * time.Millisecond, MaxRetryAfter: 20 * time.Millisecond}, 20 * time.Millisecond},
+		{"seconds, unbounded", seconds, RetryPolicy{}, 0},
+		{"date, MaxBackoff", date, RetryPolicy{MaxBackoff: 10 * time.Millisecond}, 10 * time.Millisecond},
+		{"date, MaxRetryAfter", date, RetryPolicy{MaxRetryAfter: 20 * time.Millisecond}, 20 * time.Millisecond},
+		{"date, unbounded", date, RetryPolicy{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter())
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "text/xml")
+				w.Write([]byte(pongEnvelope))
+			}))
+			defer srv.Close()
+
+			var delays []time.Duration
+			policy := tt.policy
+			policy.MaxAttempts, policy.Backoff, policy.Jitter = 2, time.Millisecond, NoJitter
+			c := &Client{
+				URL:     srv.URL,
+				Retry:   &policy,
+				Timeout: 200 * time.Millisecond,
+				OnRetry: func(attempt int, delay time.Duration, err error) { delays = append(delays, delay) },
+			}
+			err := c.RoundTrip(&ping{}, &ping{})
+			if len(delays) != 1 {
+				t.Fatalf("OnRetry called %d times, want 1", len(delays))
+			}
+			if tt.want == 0 {
+				if delays[0] < 59*time.Minute || delays[0] > time.Hour {
+					t.Errorf("delay = %v, want about an hour", delays[0])
+				}
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got error %v, want the call timed out while waiting", err)
+				}
+				return
+			}
+			if delays[0] != tt.want {
+				t.Errorf("delay = %v, want %v", delays[0], tt.want)
+			}
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package soap
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// SAXHandler receives the content of a response Body as a stream of
+// events, for processing responses too large to be decoded at once. An
+// error returned by a method stops decoding and fails the call.
+type SAXHandler interface {
+	OnStart(xml.StartElement) error
+	OnEnd(xml.EndElement) error
+	OnText(xml.CharData) error
+}
+
+// RoundTripSAX is like RoundTrip, but passes the children of the response
+// Body to handler as they are read instead of decoding a struct. A Fault
+// response is detected and returned as an error before handler is called.
+// The tokens passed to handler are only valid until its method returns.
+func (c *Client) RoundTripSAX(in Message, handler SAXHandler) error {
+	return c.RoundTripWith(in, &saxBody{h: handler})
+}
+
+// saxBody decodes the Body element by passing its content to a SAXHandler.
+type saxBody struct {
+	h SAXHandler
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (s *saxBody) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for depth := 0; ; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			err = s.h.OnStart(t)
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+			err = s.h.OnEnd(t)
+		case xml.CharData:
+			err = s.h.OnText(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// RoundTripStream is like RoundTrip, but calls fn for every element of the
+// response Body with the given name, at any depth, instead of decoding a
+// struct, so that long lists of records are processed one at a time. The
+// name uses the syntax of WithElementName; its namespace is checked only
+// if given. fn reads the element, start included, from the decoder it is
+// passed, for example with Decode; whatever it leaves unread is skipped.
+// An error returned by fn stops decoding and fails the call.
+func (c *Client) RoundTripStream(in Message, elementName string, fn func(*xml.Decoder) error) error {
+	return c.RoundTripWith(in, &streamBody{name: tagName(elementName), fn: fn})
+}
+
+// streamBody decodes the Body element by passing the elements of the given
+// name to fn.
+type streamBody struct {
+	name xml.Name
+	fn   func(*xml.Decoder) error
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (s *streamBody) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for depth := 0; ; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != s.name.Local || (s.name.Space != "" && t.Name.Space != s.name.Space) {
+				depth++
+				continue
+			}
+			el := &elementTokens{d: d, start: &t}
+			if err := s.fn(xml.NewTokenDecoder(el)); err != nil {
+				return err
+			}
+			for el.depth > 0 || el.start != nil {
+				if _, err := el.Token(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// elementTokens is an xml.TokenReader yielding start, then the tokens read
+// from d up to the matching end element.
+type elementTokens struct {
+	d     *xml.Decoder
+	start *xml.StartElement
+	depth int
+}
+
+// Token implements the xml.TokenReader interface.
+func (el *elementTokens) Token() (xml.Token, error) {
+	if el.start != nil {
+		tok := *el.start
+		el.start = nil
+		el.depth = 1
+		return tok, nil
+	}
+	if el.depth == 0 {
+		return nil, io.EOF
+	}
+	tok, err := el.d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.(type) {
+	case xml.StartElement:
+		el.depth++
+	case xml.EndElement:
+		el.depth--
+	}
+	return tok, nil
+}
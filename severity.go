@@ -0,0 +1,84 @@
+package soap
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Severity classifies the entries a response reports, such as the
+// messages of a validation service.
+type Severity int
+
+// Severities.
+const (
+	SeverityNone    Severity = iota // not an entry
+	SeverityWarning                 // passed to Client.OnWarning
+	SeverityError                   // fails the call with a *ResponseError
+)
+
+// ResponseEntry is an element of a response classified by
+// Client.Severities.
+type ResponseEntry struct {
+	Name     xml.Name
+	Severity Severity
+	Text     string // text content of the element, trimmed
+}
+
+// ResponseError is returned for a response holding entries of
+// SeverityError. The response is decoded onto out nonetheless.
+type ResponseError struct {
+	Entries []ResponseEntry
+}
+
+func (e *ResponseError) Error() string {
+	texts := make([]string, len(e.Entries))
+	for i, entry := range e.Entries {
+		texts[i] = entry.Text
+	}
+	return "soap: response reports errors: " + strings.Join(texts, "; ")
+}
+
+// observe records the text of the response elements classified by
+// severities. depth is the depth of tok below the Body element, once read.
+func (rd *responseDecoder) observe(tok xml.Token, depth int) {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		if rd.entry != nil {
+			return
+		}
+		if s := rd.severities[t.Name.Local]; s != SeverityNone {
+			rd.entry = &ResponseEntry{Name: t.Name, Severity: s}
+			rd.entryDepth = depth
+		}
+	case xml.CharData:
+		if rd.entry != nil {
+			rd.entry.Text += string(t)
+		}
+	case xml.EndElement:
+		if rd.entry != nil && depth+1 == rd.entryDepth {
+			rd.entry.Text = strings.TrimSpace(rd.entry.Text)
+			rd.entries = append(rd.entries, *rd.entry)
+			rd.entry = nil
+		}
+	}
+}
+
+// report passes the warnings observed in a response to OnWarning, and
+// returns its errors.
+func (c *Client) report(entries []ResponseEntry) error {
+	var errs []ResponseEntry
+	for _, entry := range entries {
+		switch entry.Severity {
+		case SeverityWarning:
+			if c.OnWarning != nil {
+				c.OnWarning(entry)
+			}
+		case SeverityError:
+			errs = append(errs, entry)
+		}
+	}
+	if len(errs) > 0 {
+		return &ResponseError{Entries: errs}
+	}
+	return nil
+}
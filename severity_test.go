@@ -0,0 +1,87 @@
+package soap
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSeverities(t *testing.T) {
+	severities := map[string]Severity{"Warning": SeverityWarning, "Error": SeverityError}
+	tests := []struct {
+		name     string
+		body     string
+		warnings []ResponseEntry
+		errors   []ResponseEntry // entries of the *ResponseError, nil for none
+	}{
+		{
+			name: "no entries",
+			body: `<Resp><Value>1</Value></Resp>`,
+		},
+		{
+			name:     "warning",
+			body:     `<Resp><Warning> low stock </Warning><Value>1</Value></Resp>`,
+			warnings: []ResponseEntry{{Name: xml.Name{Local: "Warning"}, Severity: SeverityWarning, Text: "low stock"}},
+		},
+		{
+			name: "errors and warnings",
+			body: `<Resp><Error>bad sku</Error><Warning>w</Warning><Value>1</Value><Error>bad qty</Error></Resp>`,
+			warnings: []ResponseEntry{
+				{Name: xml.Name{Local: "Warning"}, Severity: SeverityWarning, Text: "w"},
+			},
+			errors: []ResponseEntry{
+				{Name: xml.Name{Local: "Error"}, Severity: SeverityError, Text: "bad sku"},
+				{Name: xml.Name{Local: "Error"}, Severity: SeverityError, Text: "bad qty"},
+			},
+		},
+		{
+			name: "nested entries",
+			body: `<Resp><Error>bad <Warning>deprecated</Warning> sku</Error><Value>1</Value></Resp>`,
+			errors: []ResponseEntry{
+				{Name: xml.Name{Local: "Error"}, Severity: SeverityError, Text: "bad deprecated sku"},
+			},
+		},
+		{
+			name:     "namespaced",
+			body:     `<Resp xmlns:v="urn:v"><v:Warning>w</v:Warning><Value>1</Value></Resp>`,
+			warnings: []ResponseEntry{{Name: xml.Name{Space: "urn:v", Local: "Warning"}, Severity: SeverityWarning, Text: "w"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serve(http.StatusOK, "text/xml", `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body>`+
+				tt.body+`</soapenv:Body></soapenv:Envelope>`)
+			defer srv.Close()
+
+			var warnings []ResponseEntry
+			c := &Client{
+				URL:        srv.URL,
+				Severities: severities,
+				OnWarning:  func(entry ResponseEntry) { warnings = append(warnings, entry) },
+			}
+			var out struct {
+				Resp struct {
+					Value int `xml:"Value"`
+				} `xml:"Resp"`
+			}
+			err := c.RoundTrip(&ping{}, &out)
+			var respErr *ResponseError
+			switch {
+			case tt.errors == nil && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.errors != nil && !errors.As(err, &respErr):
+				t.Errorf("got error %v, want a *ResponseError", err)
+			case tt.errors != nil && !reflect.DeepEqual(respErr.Entries, tt.errors):
+				t.Errorf("errors = %+v, want %+v", respErr.Entries, tt.errors)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings = %+v, want %+v", warnings, tt.warnings)
+			}
+			if out.Resp.Value != 1 {
+				t.Errorf("Value = %d, want the response decoded", out.Resp.Value)
+			}
+		})
+	}
+}
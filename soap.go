@@ -2,18 +2,35 @@
 package soap
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
 // XSINamespace is a link to the XML Schema instance namespace.
 const XSINamespace = "http://www.w3.org/2001/XMLSchema-instance"
 
+// utf8BOM is the UTF-8 encoding of the byte order mark.
+const utf8BOM = "\xef\xbb\xbf"
+
+// SOAP envelope namespaces, used by default when Client.Envelope is empty.
+const (
+	SOAP11EnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	SOAP12EnvelopeNamespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
 var xmlTyperType reflect.Type = reflect.TypeOf((*XMLTyper)(nil)).Elem()
 
 // A RoundTripper executes a request passing the given req as the SOAP
@@ -33,25 +50,95 @@ type Message interface{}
 type Header interface{}
 
 // AuthHeader is a Header to be encoded as the SOAP Header element in
-// requests, to convey credentials for authentication.
+// requests, to convey credentials for authentication. Services following
+// WS-Security expect a SecurityHeader instead.
 type AuthHeader struct {
 	Namespace string `xml:"xmlns:soapenv,attr"`
 	Username  string `xml:"ns:username"`
 	Password  string `xml:"ns:password"`
 }
 
-// Client is a SOAP client.
+// Client is a SOAP client. Namespaces, ResponseNames, Severities,
+// TrimWhitespace and MultiRef rewrite the tokens of a response before they
+// are decoded, which leaves the ",innerxml" fields of responses empty.
 type Client struct {
-	URL                    string               // URL of the server
-	Namespace              string               // SOAP Namespace
-	ThisNamespace          string               // SOAP This-Namespace (tns)
-	ExcludeActionNamespace bool                 // Include Namespace to SOAP Action header
-	Envelope               string               // Optional SOAP Envelope
-	Header                 Header               // Optional SOAP Header
-	ContentType            string               // Optional Content-Type (default text/xml)
-	Config                 *http.Client         // Optional HTTP client
-	Pre                    func(*http.Request)  // Optional hook to modify outbound requests
-	Post                   func(*http.Response) // Optional hook to snoop inbound responses
+	URL                    string                                            // URL of the server
+	Namespace              string                                            // SOAP Namespace
+	ThisNamespace          string                                            // SOAP This-Namespace (tns), prefixing the actions derived from request types
+	BodyNamespace          string                                            // Optional default namespace (xmlns) declared on the Body element
+	WrapperPrefix          string                                            // Optional prefix of the wrapper element named by WithElementName
+	Namespaces             map[string]string                                 // Optional namespaces by prefix, declared on requests and matched by prefix in responses
+	ExtraNamespaces        map[string]string                                 // Optional namespaces by prefix, only declared on requests
+	ExcludeActionNamespace bool                                              // Include Namespace to SOAP Action header
+	ActionFormatter        func(namespace, operation string) string          // Optional formatting of the action derived from the request type and ThisNamespace, such as URNAction
+	ActionResolver         func(in Message) string                           // Optional mapping of request messages to their action, falling back to the type name if it returns ""
+	Envelope               string                                            // Optional SOAP Envelope namespace (default per SOAP version)
+	EnvelopePrefix         string                                            // Optional prefix of the Envelope, Header and Body elements of requests (default soapenv)
+	Header                 Header                                            // Optional SOAP Header
+	Headers                []Header                                          // Optional further SOAP Headers, whose blocks are merged after those of Header
+	ContentType            string                                            // Optional Content-Type (default text/xml)
+	Charset                string                                            // Optional charset label of the default content types, sent as written (default utf-8)
+	AcceptCharset          string                                            // Optional Accept-Charset header of requests
+	CharsetReader          CharsetReader                                     // Optional converter of responses declaring a charset other than UTF-8, e.g. charset.NewReaderLabel of golang.org/x/net/html/charset
+	ActionPlacement        ActionPlacement                                   // Optional placement of the SOAP 1.1 action (default ActionHeader)
+	Config                 *http.Client                                      // Optional HTTP client, whose Jar, if any, keeps cookies across calls (see EnableCookieJar)
+	Pre                    func(*http.Request)                               // Optional hook to modify outbound requests
+	Post                   func(*http.Response)                              // Optional hook to snoop inbound responses
+	FaultSignal            func(*http.Response) bool                         // Optional check forcing a 200 response to be parsed as a fault
+	FaultExtractor         func(out Message) error                           // Optional check of decoded responses, returning the errors they embed
+	Severities             map[string]Severity                               // Optional classification of response elements by local name, as warnings or errors
+	OnWarning              func(ResponseEntry)                               // Optional hook receiving the response elements classified as warnings
+	Retry                  *RetryPolicy                                      // Optional retry policy for failed calls
+	RetryBudget            *RetryBudget                                      // Optional budget shared by the retries of all calls
+	ByteBudget             int64                                             // Optional number of bytes sent and received (see Transferred) after which calls fail with a *ByteBudgetError
+	OnRetry                func(attempt int, delay time.Duration, err error) // Optional hook called before waiting to retry a failed attempt
+	Timeout                time.Duration                                     // Optional bound of every round trip, retries and response decoding included, but not of SendEnvelope
+	Indent                 string                                            // Optional indentation of the serialized envelope
+	CRLF                   bool                                              // Terminate lines of the serialized envelope with CRLF
+	RequestBOM             bool                                              // Start serialized requests with a UTF-8 byte order mark
+	ExpandEmptyElements    bool                                              // Rewrite self-closing tags of serialized requests as start and end tag pairs
+	StreamRequest          bool                                              // Encode requests while sending them, as ElementStream requests are: neither retried, logged, compressed nor size-checked
+	ForceChunked           bool                                              // Send requests with chunked Transfer-Encoding rather than a Content-Length, which only streamed requests lack otherwise
+	Compress               bool                                              // Gzip requests, except streamed ones, and ask for gzip responses
+	CompressThreshold      int                                               // Size in bytes up to which requests are sent uncompressed despite Compress
+	MaxRequestSize         int                                               // Optional limit on the size of serialized requests, not applied to streamed ones
+	MaxResponseSize        int64                                             // Optional limit on the size of response bodies, decompressed, checked against Content-Length and enforced while reading them
+	EmptyPolicy            EmptyPolicy                                       // Encoding of nil and empty request fields (default: omitted)
+	SkipZeroXMLType        bool                                              // Do not call SetXMLType on XMLTyper values that are zero
+	ResponseNames          map[string]string                                 // Optional renaming of response elements, from server to struct tag local name
+	CaseInsensitive        bool                                              // Match the Envelope, Header, Body and Fault elements of responses regardless of case
+	ResponseEnvelopeNS     string                                            // Optional namespace required of response envelopes (default any)
+	TrimWhitespace         bool                                              // Drop whitespace-only text of responses, such as pretty-printing indentation
+	MultiRef               bool                                              // Resolve the href="#id" (SOAP 1.1) and enc:ref (SOAP 1.2) references of multiref-encoded responses
+	MaxElements            int                                               // Optional limit on the number of elements within a response Body, enforced while decoding it
+	SniffCompression       bool                                              // Decompress gzip responses lacking a Content-Encoding header
+	LatencySamples         int                                               // Optional number of recent call durations kept for Stats
+	OnComplete             func(CallStats)                                   // Optional hook called when a call ends
+	OnStart                func(context.Context, string) context.Context     // Optional hook starting a call of the given action, returning the context of its requests, e.g. holding a tracing span
+	OnFinish               func(ctx context.Context, err error)              // Optional hook ending a call, given the context returned by OnStart
+	MetricLabel            func(action string) string                        // Optional label of the calls of an action in CallStats (default the action)
+	ConnectionKey          func(action string) string                        // Optional key giving each group of actions its own connection pool
+	MaxResponseHeaderBytes int64                                             // Optional limit on response header size, applied when Config is nil
+	MaxIdleConnsPerHost    int                                               // Optional limit on idle connections kept per host, applied when Config is nil
+	MaxConnsPerHost        int                                               // Optional limit on connections per host, applied when Config is nil
+	NoFollowRedirects      bool                                              // Fail calls answered with a redirect instead of following it
+	FollowRedirectsAsPost  bool                                              // Resend requests as POST to the Location of 301, 302 and 303 redirects instead of failing with a *RedirectError, without credentials once the host changes
+	Debug                  bool                                              // Log request and response envelopes
+	Logf                   func(string, ...interface{})                      // Optional logger for Debug (default log.Printf)
+	LogContext             func(context.Context, string, ...interface{})     // Optional logger for Debug given the call context, preferred to Logf
+	Redact                 func([]byte) []byte                               // Optional mask applied to envelopes before they are logged
+	OnRequestBody          func([]byte)                                      // Optional hook receiving each serialized request envelope, not to be modified
+	OnResponseBody         func([]byte)                                      // Optional hook receiving each raw response body, not to be modified
+
+	mu      sync.Mutex
+	clients map[string]*http.Client // HTTP clients by ConnectionKey
+	own     *http.Client            // HTTP client built when Config is nil, if needed
+	latency *latencyRing            // recent call durations, if LatencySamples is set
+
+	middleware []Middleware // see Use
+	auth       *credentials // see BasicAuth and DigestAuth
+
+	sentBytes, receivedBytes int64 // bytes transferred, see Transferred
 }
 
 /*
@@ -68,9 +155,15 @@ type BusClient struct {
 	AcceptEncoding string               //defaut:
 	AcceptLanguage string               //default:
 	CacheControl   string               //cache
-	Keepalive      bool                 //default true
+	Keepalive      bool                 //send Connection: keep-alive explicitly; connections are reused unless NoKeepalive is set
+	NoKeepalive    bool                 //send Connection: close, opening a connection per request
 	Pre            func(*http.Request)  //hook to modify outbound requests
 	Post           func(*http.Response) //hook to snoop inbound responses
+	Retry          *RetryPolicy         //optional retry policy, as for Client
+	RetryBudget    *RetryBudget         //optional budget shared by the retries
+	Timeout        time.Duration        //optional bound of every call, retries and reading the response included, as for Client
+
+	OnRetry func(attempt int, delay time.Duration, err error) //optional hook called before waiting to retry
 }
 
 // XMLTyper is an abstract interface for types that can set an XML type.
@@ -78,231 +171,997 @@ type XMLTyper interface {
 	SetXMLType()
 }
 
-func setXMLType(v reflect.Value) {
+// setXMLType calls SetXMLType on the XMLTyper values reachable from v,
+// skipping those that are zero if skipZero is set.
+func setXMLType(v reflect.Value, skipZero bool) {
 	if !v.IsValid() {
 		return
 	}
 	switch v.Type().Kind() {
 	case reflect.Interface:
-		setXMLType(v.Elem())
+		setXMLType(v.Elem(), skipZero)
 	case reflect.Ptr:
 		if v.IsNil() {
 			break
 		}
 		ok := v.Type().Implements(xmlTyperType)
-		if ok {
+		if ok && !(skipZero && v.Elem().IsZero()) {
 			v.MethodByName("SetXMLType").Call(nil)
 		}
-		setXMLType(v.Elem())
+		setXMLType(v.Elem(), skipZero)
 	case reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
-			setXMLType(v.Index(i))
+			setXMLType(v.Index(i), skipZero)
 		}
 	case reflect.Struct:
 		for i := 0; i < v.NumField(); i++ {
 			if v.Field(i).CanAddr() {
-				setXMLType(v.Field(i).Addr())
+				setXMLType(v.Field(i).Addr(), skipZero)
 			} else {
-				setXMLType(v.Field(i))
+				setXMLType(v.Field(i), skipZero)
 			}
 		}
 	}
 }
 
-func doRoundTrip(c *Client, setHeaders func(*http.Request), in, out Message) error {
-	setXMLType(reflect.ValueOf(in))
+func doRoundTrip(ctx context.Context, c *Client, o *callOptions, action string, setHeaders func(*http.Request), in, out Message) error {
+	return c.doCall(ctx, o, action, func(ctx context.Context, rec *callRecord) error {
+		if len(c.middleware) == 0 {
+			return c.roundTrip(ctx, rec, o, action, setHeaders, in, out)
+		}
+		call := &Call{Action: action, In: in, Out: out, Header: make(http.Header)}
+		return c.withMiddleware(func(ctx context.Context, call *Call) error {
+			co := *o
+			co.header = make(http.Header)
+			for key, values := range o.header {
+				co.header[key] = values
+			}
+			for key, values := range call.Header {
+				co.header[key] = values
+			}
+			resp := new(Response)
+			co.response = resp
+			err := c.roundTrip(ctx, rec, &co, action, setHeaders, call.In, call.Out)
+			if resp.StatusCode != 0 {
+				call.Response = resp
+				if o.response != nil {
+					*o.response = *resp
+				}
+			}
+			return err
+		})(ctx, call)
+	})
+}
+
+// doCall runs fn as a call of the given action: recorded for CallStats,
+// passed to OnStart and OnFinish, and bounded by its timeout. Every kind of
+// round trip goes through it.
+func (c *Client) doCall(ctx context.Context, o *callOptions, action string, fn func(context.Context, *callRecord) error) (err error) {
+	rec := c.startCall(o, action)
+	defer func() { rec.finish(err) }()
+	if c.OnStart != nil {
+		ctx = c.OnStart(ctx, action)
+	}
+	if c.OnFinish != nil {
+		defer func(ctx context.Context) { c.OnFinish(ctx, err) }(ctx)
+	}
+	timeout := o.timeout
+	if timeout == 0 {
+		timeout = c.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx = context.WithValue(ctx, operationKey{}, action)
+	return fn(ctx, rec)
+}
+
+// roundTrip sends in and decodes the response onto out.
+func (c *Client) roundTrip(ctx context.Context, rec *callRecord, o *callOptions, action string, setHeaders func(*http.Request), in, out Message) error {
+	resp, err := c.send(ctx, o, action, setHeaders, c.envelope(o, action, in))
+	if err != nil {
+		return err
+	}
+	rec.status, rec.closed = resp.StatusCode, resp.Close
+	return c.receive(ctx, o, resp, out)
+}
+
+// isSOAPContentType reports whether a response of the given content type
+// may hold a SOAP envelope.
+func isSOAPContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/xml", "application/soap+xml", "application/xml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+xml")
+}
+
+// ContentTypeError is returned for a successful response whose
+// Content-Type is not an XML type, such as an HTML error page served by a
+// proxy, rather than an error decoding it.
+type ContentTypeError struct {
+	ContentType string
+	Snippet     string // beginning of the body
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("soap: unexpected response content type %q: %q", e.ContentType, e.Snippet)
+}
+
+// charsetReader returns the converter of non-UTF-8 responses of a call.
+func (c *Client) charsetReader(o *callOptions) CharsetReader {
+	if o.charsetReader != nil {
+		return o.charsetReader
+	}
+	return c.CharsetReader
+}
+
+// contentTypeError returns the error for a response of the unexpected
+// content type ct, quoting the beginning of its body.
+func contentTypeError(ct string, body io.Reader) *ContentTypeError {
+	snippet, _ := ioutil.ReadAll(io.LimitReader(body, 512))
+	return &ContentTypeError{ContentType: ct, Snippet: string(snippet)}
+}
+
+// readErrorBody reads the body of an error response, of which only the
+// first MiB is kept.
+func readErrorBody(body io.Reader) []byte {
+	b, _ := ioutil.ReadAll(io.LimitReader(body, 1024*1024))
+	return b
+}
+
+// httpError returns the error for a response with a status other than 200
+// and the given body.
+func httpError(resp *http.Response, body []byte) *HTTPError {
+	after, _ := retryAfter(resp)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Msg:        string(body),
+		RetryAfter: after,
+	}
+}
+
+// sniffGzip returns r, decompressed if it starts with the gzip magic
+// number.
+func sniffGzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil // read errors surface when decoding
+	}
+	return gzip.NewReader(br)
+}
+
+// envelope returns the request envelope of a call carrying in.
+func (c *Client) envelope(o *callOptions, action string, in Message) *Envelope {
+	bodyType := reflect.TypeOf(in)
+	setXMLType(reflect.ValueOf(in), c.SkipZeroXMLType)
+	if c.EmptyPolicy != EmptyOmit && !isStream(in) {
+		in = applyEmptyPolicy(reflect.ValueOf(in), c.EmptyPolicy)
+	}
 
 	req := &Envelope{
 		EnvelopeAttr: c.Envelope,
 		//NSAttr:       c.Namespace,
-		//TNSAttr: c.ThisNamespace,
-		XSIAttr: XSINamespace,
-		Header:  c.Header,
-		Body:    in,
+		XSIAttr:    XSINamespace,
+		Header:     c.header(),
+		Body:       in,
+		BodyNSAttr: c.BodyNamespace,
+		Namespaces: c.envelopeNamespaces(),
+		Prefix:     c.EnvelopePrefix,
+	}
+	if o.envelopeNS != "" {
+		req.EnvelopeAttr = o.envelopeNS
+	}
+	if o.elementName.Local != "" {
+		req.Body = bodyElement{name: o.elementName, prefix: c.WrapperPrefix, v: in}
+	}
+	if c.ActionPlacement&ActionAddressing != 0 && action != "" {
+		req.Header = actionHeader{h: req.Header, action: action}
 	}
 
 	if req.EnvelopeAttr == "" {
-		req.EnvelopeAttr = "http://schemas.xmlsoap.org/soap/envelope/"
+		req.EnvelopeAttr = SOAP11EnvelopeNamespace
+		if o.version == SOAP12 {
+			req.EnvelopeAttr = SOAP12EnvelopeNamespace
+		}
 	}
 	/*
 		if req.NSAttr == "" {
 			req.NSAttr = c.URL
 		}
 	*/
-	if c.ThisNamespace != "" {
-		req.TNSAttr = c.ThisNamespace
+	req.bodyType = bodyType
+	return req
+}
+
+// envelopeNamespaces returns the namespaces declared on the Envelope of
+// requests: Namespaces, and those of ExtraNamespaces of other prefixes.
+func (c *Client) envelopeNamespaces() map[string]string {
+	if len(c.ExtraNamespaces) == 0 {
+		return c.Namespaces
+	}
+	m := make(map[string]string, len(c.Namespaces)+len(c.ExtraNamespaces))
+	for prefix, uri := range c.ExtraNamespaces {
+		m[prefix] = uri
 	}
+	for prefix, uri := range c.Namespaces {
+		m[prefix] = uri
+	}
+	return m
+}
 
-	var b bytes.Buffer
-	err := xml.NewEncoder(&b).Encode(req)
-	if err != nil {
-		return err
+// header returns the Header of requests: Header alone, or merged with
+// Headers if there are any.
+func (c *Client) header() Header {
+	if len(c.Headers) == 0 {
+		return c.Header
 	}
-	//v, vv := xml.MarshalIndent(req, "", "         ")
-	//fmt.Println("-------------------", string(v), vv)
-	cli := c.Config
-	if cli == nil {
-		cli = http.DefaultClient
+	hs := make(mergedHeader, 0, len(c.Headers)+1)
+	if c.Header != nil {
+		hs = append(hs, c.Header)
 	}
-	r, err := http.NewRequest("POST", c.URL, &b)
-	if err != nil {
+	return append(hs, c.Headers...)
+}
+
+// receive checks the response of a call and decodes it onto out, closing
+// its body.
+func (c *Client) receive(ctx context.Context, o *callOptions, resp *http.Response, out Message) error {
+	defer resp.Body.Close()
+	var body io.Reader = newTransportReader(ctx, resp, resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		// set when the transport leaves decompression to us, as it does
+		// once Accept-Encoding is set explicitly
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return decodeError(err)
+		}
+		body = zr
+	}
+	if o.response != nil {
+		*o.response = Response{StatusCode: resp.StatusCode, Header: resp.Header, ConnectionClosed: resp.Close}
+		if resp.Request != nil {
+			o.response.URL = resp.Request.URL
+		}
+	}
+	if c.MaxResponseSize > 0 && resp.ContentLength > c.MaxResponseSize && resp.StatusCode == http.StatusOK {
+		// no need to read a body declared too large
+		return &ResponseSizeError{Limit: c.MaxResponseSize}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body := readErrorBody(c.limitResponse(body))
+		c.received(ctx, body)
+		httpErr := httpError(resp, body)
+		if isSOAPContentType(resp.Header.Get("Content-Type")) {
+			// SOAP 1.1 servers answer faults with a 500
+			rd := &responseDecoder{foldCase: c.CaseInsensitive, envNS: c.ResponseEnvelopeNS, charset: c.charsetReader(o), detail: o.faultDetail}
+			switch err := rd.decode(bytes.NewReader(body), nil).(type) {
+			case *Fault:
+				httpErr.Fault, httpErr.fault = err, err
+			case *MustUnderstandFault:
+				httpErr.Fault, httpErr.fault = err.Fault, err
+			}
+		}
+		return httpErr
+	}
+
+	if c.SniffCompression {
+		var err error
+		if body, err = sniffGzip(body); err != nil {
+			return decodeError(err)
+		}
+	}
+	// limit the body once decompressed, however it was
+	body = c.limitResponse(body)
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !isSOAPContentType(ct) {
+		err := contentTypeError(ct, body)
+		c.received(ctx, []byte(err.Snippet))
 		return err
 	}
-	setHeaders(r)
-	if c.Pre != nil {
-		c.Pre(r)
+	if c.Debug || c.OnResponseBody != nil {
+		raw, err := ioutil.ReadAll(body)
+		if err != nil {
+			return decodeError(err)
+		}
+		c.received(ctx, raw)
+		body = bytes.NewReader(raw)
+	}
+
+	rd := &responseDecoder{
+		forceFault: c.FaultSignal != nil && c.FaultSignal(resp),
+		renames:    c.ResponseNames,
+		foldCase:   c.CaseInsensitive,
+		header:     o.responseHeader,
+		maxElems:   c.MaxElements,
+		expected:   o.expected,
+		bare:       o.bare,
+		prefixes:   prefixes(c.Namespaces),
+		trimSpace:  c.TrimWhitespace,
+		envNS:      c.ResponseEnvelopeNS,
+		severities: c.Severities,
+		charset:    c.charsetReader(o),
+		multiRef:   c.MultiRef,
+		detail:     o.faultDetail,
+	}
+	if err := rd.decode(body, out); err != nil {
+		return decodeError(err)
 	}
-	resp, err := cli.Do(r)
-	if err != nil {
+	if err := c.report(rd.entries); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if c.Post != nil {
-		c.Post(resp)
+	if c.FaultExtractor != nil && out != nil {
+		return c.FaultExtractor(out)
 	}
-	if resp.StatusCode != http.StatusOK {
-		// read only the first MiB of the body in error case
-		limReader := io.LimitReader(resp.Body, 1024*1024)
-		body, _ := ioutil.ReadAll(limReader)
-		return &HTTPError{
-			StatusCode: resp.StatusCode,
-			Status:     resp.Status,
-			Msg:        string(body),
+	return nil
+}
+
+// send encodes env and posts it to the server, retrying according to the
+// Client's RetryPolicy. The caller must close the response body.
+func (c *Client) send(ctx context.Context, o *callOptions, action string, setHeaders func(*http.Request), env *Envelope) (*http.Response, error) {
+	var b []byte
+	streamed := c.StreamRequest || isStream(env.Body)
+	if !streamed {
+		var err error
+		if b, err = c.encode(env); err != nil {
+			return nil, err
+		}
+		if err := c.checkSize(b); err != nil {
+			return nil, err
+		}
+		//v, vv := xml.MarshalIndent(req, "", "         ")
+		//fmt.Println("-------------------", string(v), vv)
+		c.sent(ctx, b)
+		if b, setHeaders, err = c.compress(b, setHeaders); err != nil {
+			return nil, err
 		}
 	}
+	return c.post(ctx, o, action, setHeaders, !streamed, func() io.Reader {
+		if streamed {
+			return c.encodeStream(env)
+		}
+		return bytes.NewReader(b)
+	})
+}
 
-	marshalStructure := struct {
-		XMLName xml.Name `xml:"Envelope"`
-		Body    Message
-	}{Body: out}
+// emptyElementTag matches a self-closing tag, with its name and attributes,
+// or else a CDATA section or comment, whose content is not markup.
+var emptyElementTag = regexp.MustCompile(`(?s)<!\[CDATA\[.*?\]\]>|<!--.*?-->|<([\w:.\-]+)((?:\s+[\w:.\-]+\s*=\s*(?:"[^"]*"|'[^']*'))*)\s*/>`)
+
+// expandEmptyElements rewrites the self-closing tags of b, as <x/>, into
+// start and end tag pairs, as <x></x>. encoding/xml never writes them, but
+// they may come from ",innerxml" fields.
+func expandEmptyElements(b []byte) []byte {
+	return emptyElementTag.ReplaceAllFunc(b, func(tag []byte) []byte {
+		m := emptyElementTag.FindSubmatch(tag)
+		if m[1] == nil {
+			return tag // CDATA section or comment
+		}
+		out := make([]byte, 0, len(tag)+len(m[1])+3)
+		out = append(append(append(out, '<'), m[1]...), m[2]...)
+		return append(append(append(out, "></"...), m[1]...), '>')
+	})
+}
+
+// compress returns the serialized envelope b gzipped if Compress is set and
+// b exceeds CompressThreshold, along with setHeaders extended to declare it.
+func (c *Client) compress(b []byte, setHeaders func(*http.Request)) ([]byte, func(*http.Request), error) {
+	if !c.Compress || len(b) <= c.CompressThreshold {
+		return b, setHeaders, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), func(r *http.Request) {
+		setHeaders(r)
+		r.Header.Set("Content-Encoding", "gzip")
+	}, nil
+}
+
+// RequestSizeError is returned, before anything is sent, for a request
+// whose envelope exceeds Client.MaxRequestSize.
+type RequestSizeError struct {
+	Size  int // size of the serialized envelope
+	Limit int
+}
+
+func (e *RequestSizeError) Error() string {
+	return fmt.Sprintf("soap: request of %d bytes exceeds %d bytes", e.Size, e.Limit)
+}
+
+// checkSize checks the serialized envelope b against MaxRequestSize.
+func (c *Client) checkSize(b []byte) error {
+	if c.MaxRequestSize > 0 && len(b) > c.MaxRequestSize {
+		return &RequestSizeError{Size: len(b), Limit: c.MaxRequestSize}
+	}
+	return nil
+}
+
+// ResponseSizeError is returned for a response whose body exceeds
+// Client.MaxResponseSize.
+type ResponseSizeError struct {
+	Limit int64
+}
+
+func (e *ResponseSizeError) Error() string {
+	return fmt.Sprintf("soap: response exceeds %d bytes", e.Limit)
+}
+
+// sizeLimit is an io.Reader failing with a *ResponseSizeError once r yields
+// more than limit bytes. Unlike a check of the Content-Length, it also
+// bounds chunked and decompressed responses.
+type sizeLimit struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *sizeLimit) Read(p []byte) (int, error) {
+	left := l.limit - l.read
+	if left < 0 {
+		return 0, &ResponseSizeError{Limit: l.limit}
+	}
+	if int64(len(p)) > left+1 {
+		p = p[:left+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > left {
+		l.read = l.limit + 1
+		return int(left), &ResponseSizeError{Limit: l.limit}
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// limitResponse returns r limited to MaxResponseSize, if set.
+func (c *Client) limitResponse(r io.Reader) io.Reader {
+	if c.MaxResponseSize > 0 {
+		return &sizeLimit{r: r, limit: c.MaxResponseSize}
+	}
+	return r
+}
+
+// post sends the body returned by newBody, called once per attempt. The
+// call is retried according to the RetryPolicy only if retryable is set.
+// The caller must close the response body.
+func (c *Client) post(ctx context.Context, o *callOptions, action string, setHeaders func(*http.Request), retryable bool, newBody func() io.Reader) (*http.Response, error) {
+	if err := c.checkBytes(); err != nil {
+		return nil, err
+	}
+	var redirect *RedirectError
+	var base doer = countingDoer{doer: guardRedirects(c.httpClient(o, action), c.NoFollowRedirects, &redirect), c: c}
+	d := base
+	if c.auth != nil {
+		d = authDoer{doer: d, cred: c.auth}
+	}
+	x := &exchange{
+		doer:    d,
+		pre:     c.Pre,
+		post:    c.Post,
+		retry:   c.Retry,
+		budget:  c.RetryBudget,
+		onRetry: c.OnRetry,
+		check: func() error {
+			if redirect != nil {
+				return redirect
+			}
+			return nil
+		},
+	}
+	if !retryable {
+		x.retry = nil
+	}
+	crossHost := false // whether a redirect left the host of c.URL
+	headers := func(r *http.Request) {
+		setHeaders(r)
+		if c.AcceptCharset != "" {
+			r.Header.Set("Accept-Charset", c.AcceptCharset)
+		}
+		if c.Compress {
+			r.Header.Set("Accept-Encoding", "gzip")
+		}
+		if !crossHost {
+			// the headers of the call may carry tokens
+			for key, values := range o.header {
+				r.Header[key] = values
+			}
+		}
+		if c.ForceChunked && r.Body != nil && r.Body != http.NoBody {
+			r.ContentLength = -1 // sent chunked
+		}
+	}
+	url := c.URL
+	for hops := 1; ; hops++ {
+		resp, err := x.do(ctx, url, newBody, headers)
+		if redirect == nil || redirect.Err != nil || !c.FollowRedirectsAsPost || !retryable {
+			return resp, err
+		}
+		if hops >= 10 {
+			return nil, fmt.Errorf("soap: stopped after %d redirects: %w", hops, redirect)
+		}
+		// resend the request as POST, which the HTTP client would not do
+		url, redirect = redirect.Location, nil
+		if !crossHost && !sameHost(url, c.URL) {
+			// like net/http, do not send credentials to another host,
+			// whichever of setHeaders and Pre sets them
+			crossHost = true
+			x.doer = base
+			x.pre = func(r *http.Request) {
+				if c.Pre != nil {
+					c.Pre(r)
+				}
+				for _, key := range credentialHeaders {
+					r.Header.Del(key)
+				}
+			}
+		}
+	}
+}
 
-	return xml.NewDecoder(resp.Body).Decode(&marshalStructure)
+// encode serializes env as configured on the Client.
+func (c *Client) encode(env *Envelope) ([]byte, error) {
+	var b bytes.Buffer
+	if c.RequestBOM {
+		b.WriteString(utf8BOM)
+	}
+	enc := xml.NewEncoder(&b)
+	if c.Indent != "" {
+		enc.Indent("", c.Indent)
+	}
+	if err := enc.Encode(env); err != nil {
+		return nil, &EncodeError{Type: env.messageType(), Err: err}
+	}
+	out := b.Bytes()
+	if c.ExpandEmptyElements {
+		out = expandEmptyElements(out)
+	}
+	if c.CRLF {
+		out = bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n"))
+		out = bytes.ReplaceAll(out, []byte("\n"), []byte("\r\n"))
+	}
+	return out, nil
+}
 
+// SendEnvelope posts a fully built envelope and returns the status code,
+// headers and body of the response, without checking the status or
+// decoding the body, for callers handling responses the typed round trips
+// cannot. As for those, the body is bounded by MaxResponseSize and passed
+// to OnResponseBody.
+func (c *Client) SendEnvelope(ctx context.Context, env *Envelope) (int, http.Header, []byte, error) {
+	headerFunc := func(r *http.Request) {
+		ct := c.ContentType
+		if ct == "" {
+			ct = "text/xml;charset=" + c.charset()
+		}
+		r.Header.Set("Content-Type", ct)
+	}
+	resp, err := c.send(ctx, newCallOptions(nil), "", headerFunc, env)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	var r io.Reader = newTransportReader(ctx, resp, resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		// the transport leaves decompression to us once Compress sets
+		// Accept-Encoding
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, nil, nil, decodeError(err)
+		}
+		r = zr
+	}
+	body, err := ioutil.ReadAll(c.limitResponse(r))
+	if err != nil {
+		return 0, nil, nil, decodeError(err)
+	}
+	c.received(ctx, body)
+	return resp.StatusCode, resp.Header, body, nil
 }
 
 // RoundTrip implements the RoundTripper interface.
 func (c *Client) RoundTrip(in, out Message) error {
+	return c.RoundTripWith(in, out)
+}
+
+// RoundTripWith is like RoundTrip, applying the given options to this call
+// only.
+func (c *Client) RoundTripWith(in, out Message, opts ...CallOption) error {
+	return c.RoundTripContext(context.Background(), in, out, opts...)
+}
+
+// RoundTripWithResponse is like RoundTrip, also returning the HTTP headers
+// of the response, if one was received, whether the call succeeds or not.
+func (c *Client) RoundTripWithResponse(in, out Message) (http.Header, error) {
+	var resp Response
+	err := c.RoundTripWith(in, out, WithResponse(&resp))
+	return resp.Header, err
+}
+
+// RoundTripResult is like RoundTrip, decoding the detail of a fault
+// answered by the server onto faultDetail, as WithFaultDetail does, and a
+// successful response onto out. The fault is returned as the error.
+func (c *Client) RoundTripResult(in, out, faultDetail Message) error {
+	return c.RoundTripWith(in, out, WithFaultDetail(faultDetail))
+}
+
+// RoundTripTimeout is like RoundTrip, bounding this call, retries and
+// response decoding included, by d instead of Client.Timeout.
+func (c *Client) RoundTripTimeout(d time.Duration, in, out Message) error {
+	return c.RoundTripWith(in, out, WithTimeout(d))
+}
+
+// RoundTripContext is like RoundTripWith, with a context bounding the call:
+// cancelling ctx aborts it, returning an error wrapping ctx.Err().
+func (c *Client) RoundTripContext(ctx context.Context, in, out Message, opts ...CallOption) error {
+	o := newCallOptions(opts)
+	actionName := o.action
+	if actionName == "" {
+		actionName = c.actionName(in)
+	}
 	headerFunc := func(r *http.Request) {
-		var actionName, soapAction string
-		if in != nil {
-			soapAction = reflect.TypeOf(in).Elem().Name()
+		ct := o.contentType
+		if ct == "" {
+			ct = c.ContentType
 		}
-		ct := c.ContentType
 		if ct == "" {
-			ct = "text/xml;charset=utf-8"
+			ct = "text/xml;charset=" + c.charset()
 		}
-		r.Header.Set("Content-Type", ct)
-		if in != nil {
-			if c.ExcludeActionNamespace {
-				actionName = soapAction
-			} else {
-				actionName = fmt.Sprintf("%s/%s", c.ThisNamespace, soapAction)
-			}
-			r.Header.Add("SOAPAction", actionName)
+		c.setActionHeaders(r, ct, actionName, in != nil)
+	}
+	return doRoundTrip(ctx, c, o, actionName, headerFunc, in, out)
+}
+
+// actionName returns the SOAPAction of a RoundTrip call carrying in,
+// given by ActionResolver or derived from its type name.
+func (c *Client) actionName(in Message) string {
+	if in == nil {
+		return ""
+	}
+	if c.ActionResolver != nil {
+		if action := c.ActionResolver(in); action != "" {
+			return action
 		}
 	}
-	return doRoundTrip(c, headerFunc, in, out)
+	soapAction := reflect.TypeOf(in).Elem().Name()
+	if c.ActionFormatter != nil {
+		return c.ActionFormatter(c.ThisNamespace, soapAction)
+	}
+	if c.ExcludeActionNamespace {
+		return soapAction
+	}
+	return fmt.Sprintf("%s/%s", c.ThisNamespace, soapAction)
+}
+
+// URNAction is an ActionFormatter producing actions such as
+// "urn:ServiceName:Operation" from a ThisNamespace of "ServiceName" or
+// "urn:ServiceName".
+func URNAction(namespace, operation string) string {
+	return "urn:" + strings.TrimPrefix(namespace, "urn:") + ":" + operation
 }
 
 // RoundTripWithAction implements the RoundTripper interface for SOAP clients
 // that need to set the SOAPAction header.
 func (c *Client) RoundTripWithAction(soapAction string, in, out Message) error {
+	return c.RoundTripWithActionContext(context.Background(), soapAction, in, out)
+}
+
+// RoundTripWithActionContext is like RoundTripWithAction, with a context
+// bounding the call.
+func (c *Client) RoundTripWithActionContext(ctx context.Context, soapAction string, in, out Message) error {
+	var actionName string
+	if in != nil {
+		if c.ExcludeActionNamespace {
+			actionName = soapAction
+		} else {
+			actionName = fmt.Sprintf("%s/%s", c.Namespace, soapAction)
+		}
+	}
 	headerFunc := func(r *http.Request) {
-		var actionName string
 		ct := c.ContentType
 		if ct == "" {
 			ct = "text/xml"
 		}
-		r.Header.Set("Content-Type", ct)
-		if in != nil {
-			if c.ExcludeActionNamespace {
-				actionName = soapAction
-			} else {
-				actionName = fmt.Sprintf("%s/%s", c.Namespace, soapAction)
-			}
-			r.Header.Add("SOAPAction", actionName)
-		}
+		c.setActionHeaders(r, ct, actionName, in != nil)
+	}
+	return doRoundTrip(ctx, c, newCallOptions(nil), actionName, headerFunc, in, out)
+}
+
+// charset returns the charset label of the default content types. The
+// envelope is always encoded in UTF-8; only the spelling can be changed.
+func (c *Client) charset() string {
+	if c.Charset == "" {
+		return "utf-8"
 	}
-	return doRoundTrip(c, headerFunc, in, out)
+	return c.Charset
 }
 
+// ActionPlacement selects where the action of a SOAP 1.1 call is sent.
+// The values may be combined, for servers that want the action in several
+// places at once; all of them then carry the same value.
+type ActionPlacement int
+
+// Action placements.
+const (
+	ActionHeader      ActionPlacement = 1 << iota // SOAPAction HTTP header (default)
+	ActionContentType                             // action parameter of the Content-Type, as in SOAP 1.2
+	ActionAddressing                              // wsa:Action block in the SOAP Header
+
+	ActionAll = ActionHeader | ActionContentType | ActionAddressing
+)
+
+// setActionHeaders sets the Content-Type ct of a SOAP 1.1 request, and the
+// action if withAction is set, according to the Client's ActionPlacement.
+func (c *Client) setActionHeaders(r *http.Request, ct, action string, withAction bool) {
+	placement := c.ActionPlacement
+	if placement == 0 {
+		placement = ActionHeader
+	}
+	if withAction && placement&ActionContentType != 0 {
+		ct = fmt.Sprintf("%s; action=\"%s\"", ct, action)
+	}
+	r.Header.Set("Content-Type", ct)
+	if withAction && placement&ActionHeader != 0 {
+		r.Header.Add("SOAPAction", action)
+	}
+}
+
+// RoundTripWithBus posts in to MethodName. The method argument is not
+// used; see RoundTripWithBusContext.
 func (c *BusClient) RoundTripWithBus(method string, in []byte) ([]byte, error) {
+	return c.RoundTripWithBusContext(context.Background(), c.MethodName, nil, in)
+}
+
+// RoundTripWithBusContext posts in to the given method, appended to
+// BaseURL, or to MethodName if method is empty, with a context bounding
+// the call. The headers are set on the
+// request after those configured on the BusClient.
+func (c *BusClient) RoundTripWithBusContext(ctx context.Context, method string, headers map[string]string, in []byte) ([]byte, error) {
+	if method == "" {
+		method = c.MethodName
+	}
 	headerFunc := func(r *http.Request) { //用来设置请求头的回调
-		ct := c.ContentType
-		if ct == "" {
-			ct = "application/json"
+		r.Header.Set("Content-Type", c.contentType())
+		c.setHeaders(r)
+		for key, value := range headers {
+			r.Header.Set(key, value)
 		}
-		r.Header.Set("Content-Type", ct)
+	}
+	return doRoundTripWithBus(ctx, c, method, headerFunc, in)
+}
 
+// setHeaders sets the headers configured on the BusClient on r.
+func (c *BusClient) setHeaders(r *http.Request) {
+	if c.Host != "" {
+		r.Host = c.Host
+	}
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = "Gin-Grid 1.0.1"
+	}
+	switch {
+	case c.NoKeepalive:
+		r.Header.Set("Connection", "close")
+		r.Close = true
+	case c.Keepalive:
+		r.Header.Set("Connection", "keep-alive")
+	}
+	for key, value := range map[string]string{
+		"User-Agent":      userAgent,
+		"Accept":          c.Accept,
+		"Accept-Encoding": c.AcceptEncoding,
+		"Accept-Language": c.AcceptLanguage,
+		"Cache-Control":   c.CacheControl,
+	} {
+		if value != "" {
+			r.Header.Set(key, value)
+		}
 	}
-	return doRoundTripWithBus(c, headerFunc, in)
 }
 
-func doRoundTripWithBus(c *BusClient, setHeaders func(*http.Request), in []byte) ([]byte, error) {
+// contentType returns the Content-Type of bus requests.
+func (c *BusClient) contentType() string {
+	if c.ContentType == "" {
+		return "application/json"
+	}
+	return c.ContentType
+}
 
-	//v, vv := xml.MarshalIndent(req, "", "         ")
-	//fmt.Println("-------------------", string(v), vv)
+// doRoundTripWithBus posts in to method through the same exchange as SOAP
+// calls, with its retries, and returns the response body. An HTML page, as
+// proxies and gateways answer in place of the service, fails with a
+// *ContentTypeError; other content types are returned as they are.
+func doRoundTripWithBus(ctx context.Context, c *BusClient, method string, setHeaders func(*http.Request), in []byte) ([]byte, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 	cli := c.Config
 	if cli == nil {
 		cli = http.DefaultClient
 	}
-	r, err := http.NewRequest("POST", c.BaseURL+c.MethodName, bytes.NewBuffer(in))
-	if err != nil {
-		return nil, err
+	x := &exchange{
+		doer:    cli,
+		pre:     c.Pre,
+		post:    c.Post,
+		retry:   c.Retry,
+		budget:  c.RetryBudget,
+		onRetry: c.OnRetry,
 	}
-	setHeaders(r)
-	if c.Pre != nil {
-		c.Pre(r)
-	}
-	resp, err := cli.Do(r)
+	resp, err := x.do(ctx, c.BaseURL+method, func() io.Reader {
+		return bytes.NewReader(in)
+	}, setHeaders)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	if c.Post != nil {
-		c.Post(resp)
-	}
 	if resp.StatusCode != http.StatusOK {
-		// read only the first MiB of the body in error case
-		limReader := io.LimitReader(resp.Body, 1024*1024)
-		body, _ := ioutil.ReadAll(limReader)
-		return nil, &HTTPError{
-			StatusCode: resp.StatusCode,
-			Status:     resp.Status,
-			Msg:        string(body),
-		}
+		return nil, httpError(resp, readErrorBody(resp.Body))
+	}
+	if ct := resp.Header.Get("Content-Type"); isHTMLContentType(ct) {
+		return nil, contentTypeError(ct, resp.Body)
 	}
 
-	return ioutil.ReadAll(resp.Body)
+	return ioutil.ReadAll(newTransportReader(ctx, resp, resp.Body))
+}
 
+// isHTMLContentType reports whether ct is the content type of an HTML page.
+func isHTMLContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
 }
 
 // RoundTripSoap12 implements the RoundTripper interface for SOAP 1.2.
 func (c *Client) RoundTripSoap12(action string, in, out Message) error {
+	return c.RoundTripSoap12Context(context.Background(), action, in, out)
+}
+
+// RoundTripSoap12Context is like RoundTripSoap12, with a context bounding
+// the call.
+func (c *Client) RoundTripSoap12Context(ctx context.Context, action string, in, out Message) error {
 	headerFunc := func(r *http.Request) {
-		r.Header.Add("Content-Type", fmt.Sprintf("application/soap+xml; charset=utf-8; action=\"%s\"", action))
+		r.Header.Add("Content-Type", fmt.Sprintf("application/soap+xml; charset=%s; action=\"%s\"", c.charset(), action))
 	}
-	return doRoundTrip(c, headerFunc, in, out)
+	o := newCallOptions(nil)
+	o.version = SOAP12
+	return doRoundTrip(ctx, c, o, action, headerFunc, in, out)
 }
 
-// HTTPError is detailed soap http error
+// SOAPVersion identifies a version of the SOAP protocol.
+type SOAPVersion int
+
+// Supported SOAP versions.
+const (
+	SOAP11 SOAPVersion = iota // SOAP 1.1
+	SOAP12                    // SOAP 1.2
+)
+
+// RoundTripVersion performs the call using the given SOAP version, for
+// services exposing both bindings. For SOAP 1.1 an empty action derives
+// the SOAPAction from the request type, as in RoundTrip.
+func (c *Client) RoundTripVersion(version SOAPVersion, action string, in, out Message) error {
+	switch version {
+	case SOAP11:
+		if action == "" {
+			return c.RoundTrip(in, out)
+		}
+		return c.RoundTripWithAction(action, in, out)
+	case SOAP12:
+		return c.RoundTripSoap12(action, in, out)
+	}
+	return fmt.Errorf("soap: unknown SOAP version %d", version)
+}
+
+// HTTPError is detailed soap http error. If the body is a SOAP fault, as
+// SOAP 1.1 servers answer with a 500, Fault holds it decoded and the
+// HTTPError unwraps to it (or to the *MustUnderstandFault).
 type HTTPError struct {
 	StatusCode int
 	Status     string
 	Msg        string
+	RetryAfter time.Duration // delay requested by the Retry-After header of a 429 or 503 response, if any
+	Fault      *Fault        // fault held by the body, if any
+
+	fault error // the decoded fault error, Fault or a *MustUnderstandFault
 }
 
 func (e *HTTPError) Error() string {
+	if e.fault != nil {
+		return fmt.Sprintf("%q: %v", e.Status, e.fault)
+	}
 	return fmt.Sprintf("%q: %q", e.Status, e.Msg)
 }
 
+func (e *HTTPError) Unwrap() error {
+	return e.fault
+}
+
 // Envelope is a SOAP envelope.
 type Envelope struct {
-	XMLName      xml.Name `xml:"soapenv:Envelope"`
-	EnvelopeAttr string   `xml:"xmlns:soapenv,attr"`
-	TNSAttr      string   `xml:"xmlns:unif,attr,omitempty"`
-	TNSAttr2     string   `xml:"xmlns:ical,attr,omitempty"`
-	XSIAttr      string   `xml:"xmlns:xsi,attr,omitempty"`
-	Header       Message  `xml:"soapenv:Header"`
-	Body         Message  `xml:"soapenv:Body"`
+	XMLName      xml.Name          `xml:"soapenv:Envelope"`
+	EnvelopeAttr string            `xml:"xmlns:soapenv,attr"`
+	XSIAttr      string            `xml:"xmlns:xsi,attr,omitempty"`
+	BodyNSAttr   string            `xml:"-"` // default namespace of the Body element, if any
+	Namespaces   map[string]string `xml:"-"` // further namespaces declared on the Envelope, by prefix
+	Prefix       string            `xml:"-"` // prefix of the Envelope, Header and Body elements (default soapenv)
+	Header       Message           `xml:"soapenv:Header"`
+	Body         Message           `xml:"soapenv:Body"`
+
+	bodyType reflect.Type // type of the request message, if Body wraps it
+}
+
+// messageType returns the name of the Go type of the request message.
+func (env *Envelope) messageType() string {
+	t := env.bodyType
+	if t == nil {
+		t = reflect.TypeOf(env.Body)
+	}
+	if t == nil {
+		return "<nil>"
+	}
+	return t.String()
+}
+
+// EncodeError is returned when the request envelope cannot be serialized.
+type EncodeError struct {
+	Type string // Go type of the request message, as in "*pkg.GetQuote"
+	Err  error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("soap: encoding request %s: %v", e.Type, e.Err)
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is returned when a request cannot be sent or its response
+// not received, as when the connection fails; retrying the call may
+// succeed. Errors of the response itself are returned as a *Fault, an
+// *HTTPError or a *DecodeError.
+type TransportError struct {
+	URL      string
+	Aborted  bool // whether the call was canceled or timed out through its context
+	Response bool // whether the body of the response was being read
+	Err      error
+}
+
+func (e *TransportError) Error() string {
+	switch {
+	case e.Aborted:
+		return fmt.Sprintf("soap: request to %s aborted: %v", e.URL, e.Err)
+	case e.Response:
+		return fmt.Sprintf("soap: reading response: %v", e.Err)
+	}
+	return fmt.Sprintf("soap: sending request: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when a response is received but its envelope
+// cannot be decoded onto the response message.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("soap: decoding response: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,162 @@
+package soap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxResponseSizeSniffedGzip(t *testing.T) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><Ping>`))
+	zw.Write([]byte(strings.Repeat(" ", 1<<20)))
+	zw.Write([]byte(`</Ping></soapenv:Body></soapenv:Envelope>`))
+	zw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(b.Bytes())
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, SniffCompression: true, MaxResponseSize: 64 << 10}
+	err := c.RoundTrip(&ping{}, &ping{})
+	var sizeErr *ResponseSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("got error %v, want a *ResponseSizeError", err)
+	}
+}
+
+func TestExpandEmptyElements(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<a/>`, `<a></a>`},
+		{`<a />`, `<a></a>`},
+		{`<ns:a/>`, `<ns:a></ns:a>`},
+		{`<a.b-c_d/>`, `<a.b-c_d></a.b-c_d>`},
+		{`<a x="1" ns:y='2'/>`, `<a x="1" ns:y='2'></a>`},
+		{`<a x="/>"/>`, `<a x="/>"></a>`},
+		{`<a x = "1" />`, `<a x = "1"></a>`},
+		{`<a><b/><c/></a>`, `<a><b></b><c></c></a>`},
+		{`<a></a>`, `<a></a>`},
+		{`<a>x/&gt;</a>`, `<a>x/&gt;</a>`},
+		{`<a><![CDATA[<b/>]]><c/></a>`, `<a><![CDATA[<b/>]]><c></c></a>`},
+		{`<!-- <b/> --><c/>`, `<!-- <b/> --><c></c>`},
+	}
+	for _, tt := range tests {
+		if got := string(expandEmptyElements([]byte(tt.in))); got != tt.want {
+			t.Errorf("expandEmptyElements(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandEmptyElementsInnerXML(t *testing.T) {
+	type raw struct {
+		XML   string `xml:",innerxml"`
+		Empty string `xml:"tns:Empty"`
+	}
+	c := &Client{ExpandEmptyElements: true}
+	b, err := c.encode(c.envelope(newCallOptions(nil), "", &raw{XML: `<tns:Flag on="true"/><Note/>`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<soapenv:Body><tns:Flag on="true"></tns:Flag><Note></Note><tns:Empty></tns:Empty></soapenv:Body></soapenv:Envelope>`
+	if got := string(b); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	c.ExpandEmptyElements = false
+	if b, err = c.encode(c.envelope(newCallOptions(nil), "", &raw{XML: `<Note/>`})); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`<Note/>`)) {
+		t.Errorf("self-closing tag expanded without ExpandEmptyElements: %s", b)
+	}
+}
+
+func TestSendEnvelopeGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(pongEnvelope))
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, Compress: true}
+	_, _, body, err := c.SendEnvelope(context.Background(), c.envelope(newCallOptions(nil), "", &ping{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != pongEnvelope {
+		t.Errorf("body = %q, want %q", body, pongEnvelope)
+	}
+}
+
+func TestSendEnvelopeLimitAndHook(t *testing.T) {
+	srv := serve(http.StatusOK, "text/xml", pongEnvelope)
+	defer srv.Close()
+
+	var hooked []byte
+	c := &Client{URL: srv.URL, OnResponseBody: func(b []byte) { hooked = b }}
+	if _, _, _, err := c.SendEnvelope(context.Background(), c.envelope(newCallOptions(nil), "", &ping{})); err != nil {
+		t.Fatal(err)
+	}
+	if string(hooked) != pongEnvelope {
+		t.Errorf("OnResponseBody got %q, want %q", hooked, pongEnvelope)
+	}
+
+	c.MaxResponseSize = 16
+	_, _, _, err := c.SendEnvelope(context.Background(), c.envelope(newCallOptions(nil), "", &ping{}))
+	var sizeErr *ResponseSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Errorf("got error %v, want a *ResponseSizeError", err)
+	}
+}
+
+func TestTimeoutAndHooks(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	var started, finished int
+	c := &Client{
+		URL:      srv.URL,
+		Timeout:  50 * time.Millisecond,
+		OnStart:  func(ctx context.Context, action string) context.Context { started++; return ctx },
+		OnFinish: func(ctx context.Context, err error) { finished++ },
+	}
+	tmpl, err := c.CompileTemplate(&ping{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := map[string]func() error{
+		"RoundTrip":         func() error { return c.RoundTrip(&ping{}, &ping{}) },
+		"RoundTripMTOM":     func() error { return c.RoundTripMTOM(&ping{}, &ping{}, nil) },
+		"RoundTripTemplate": func() error { return c.RoundTripTemplate(tmpl, nil, &ping{}) },
+	}
+	for name, call := range calls {
+		started, finished = 0, 0
+		if err := call(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: got error %v, want context.DeadlineExceeded", name, err)
+		}
+		if started != 1 || finished != 1 {
+			t.Errorf("%s: OnStart called %d times and OnFinish %d times, want 1", name, started, finished)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package soap
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Stats holds latency percentiles over the most recent calls of a Client.
+type Stats struct {
+	Count int           // Number of calls sampled
+	P50   time.Duration // Median call duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// CallStats describes a call made by a Client, as passed to OnComplete.
+type CallStats struct {
+	Action     string
+	Label      string // label of the call, for metrics (see Client.MetricLabel)
+	Duration   time.Duration
+	StatusCode int   // HTTP status of the response, 0 if none was received
+	Err        error // error returned by the call
+	Outcome    Outcome
+
+	// ConnectionClosed is set if the server closed the connection after
+	// the response, as with "Connection: close", so it cannot be reused.
+	ConnectionClosed bool
+}
+
+// Outcome classifies how a call ended, to count SOAP faults apart from
+// HTTP and transport errors.
+type Outcome int
+
+// Call outcomes.
+const (
+	OutcomeSuccess   Outcome = iota // the call succeeded
+	OutcomeFault                    // the server answered a SOAP fault
+	OutcomeHTTPError                // the server answered an HTTP error status without a fault
+	OutcomeError                    // the call failed otherwise, e.g. at the transport level or decoding
+)
+
+// String returns the name of the outcome, suitable as a metric label.
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeFault:
+		return "fault"
+	case OutcomeHTTPError:
+		return "http_error"
+	}
+	return "error"
+}
+
+// OutcomeOf returns the outcome of a call that returned err.
+func OutcomeOf(err error) Outcome {
+	var fault *Fault
+	var httpErr *HTTPError
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.As(err, &fault):
+		return OutcomeFault
+	case errors.As(err, &httpErr):
+		return OutcomeHTTPError
+	}
+	return OutcomeError
+}
+
+// callRecord measures a call for Stats and OnComplete.
+type callRecord struct {
+	c      *Client
+	o      *callOptions
+	action string
+	start  time.Time
+	status int
+	closed bool
+}
+
+func (c *Client) startCall(o *callOptions, action string) *callRecord {
+	return &callRecord{c: c, o: o, action: action, start: time.Now()}
+}
+
+// finish records the end of the call, which returned err.
+func (r *callRecord) finish(err error) {
+	c := r.c
+	if c.LatencySamples > 0 {
+		c.recordLatency(r.start)
+	}
+	if c.OnComplete == nil {
+		return
+	}
+	label := r.o.metricLabel
+	if label == "" && c.MetricLabel != nil {
+		label = c.MetricLabel(r.action)
+	}
+	if label == "" {
+		label = r.action
+	}
+	c.OnComplete(CallStats{
+		Action:     r.action,
+		Label:      label,
+		Duration:   time.Since(r.start),
+		StatusCode: r.status,
+		Err:        err,
+		Outcome:    OutcomeOf(err),
+
+		ConnectionClosed: r.closed,
+	})
+}
+
+// latencyRing keeps the durations of the last calls.
+type latencyRing struct {
+	samples []time.Duration
+	next    int // index of the next sample to overwrite, once full
+}
+
+func (r *latencyRing) add(d time.Duration, size int) {
+	if len(r.samples) < size {
+		r.samples = append(r.samples, d)
+		return
+	}
+	r.samples[r.next%len(r.samples)] = d
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+// recordLatency records the duration of a call started at start.
+func (c *Client) recordLatency(start time.Time) {
+	d := time.Since(start)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.latency == nil {
+		c.latency = new(latencyRing)
+	}
+	c.latency.add(d, c.LatencySamples)
+}
+
+// Stats returns the latency percentiles of the last LatencySamples calls,
+// successful or not. It returns a zero Stats if no call was sampled.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	var samples []time.Duration
+	if c.latency != nil {
+		samples = append(samples, c.latency.samples...)
+	}
+	c.mu.Unlock()
+	if len(samples) == 0 {
+		return Stats{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return Stats{
+		Count: len(samples),
+		P50:   percentile(samples, 50),
+		P95:   percentile(samples, 95),
+		P99:   percentile(samples, 99),
+	}
+}
+
+// percentile returns the p-th percentile of sorted, by the nearest-rank
+// method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,74 @@
+package soap
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+)
+
+// ElementStream is a request message whose Body children are produced on
+// the fly, for bulk submissions too large to hold in memory. Each element
+// received from Elements is encoded and written to the connection before
+// the next one is read; the request ends when the channel is closed.
+//
+// A streamed request is sent once: it is neither retried nor logged by
+// Debug, and CRLF does not apply to it.
+type ElementStream struct {
+	Name     xml.Name       // Optional operation element wrapping the stream
+	Elements <-chan Message // Body children, closed by the producer when done
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (s *ElementStream) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	wrapper := xml.StartElement{Name: s.Name}
+	if s.Name.Local != "" {
+		if err := e.EncodeToken(wrapper); err != nil {
+			return err
+		}
+	}
+	for el := range s.Elements {
+		setXMLType(reflect.ValueOf(el), false)
+		if err := e.Encode(el); err != nil {
+			return err
+		}
+	}
+	if s.Name.Local != "" {
+		if err := e.EncodeToken(wrapper.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// isStream reports whether the Body message is streamed.
+func isStream(body Message) bool {
+	switch v := body.(type) {
+	case *ElementStream:
+		return true
+	case bodyElement:
+		return isStream(v.v)
+	}
+	return false
+}
+
+// encodeStream returns a reader of env, encoded concurrently as the
+// reader is consumed.
+func (c *Client) encodeStream(env *Envelope) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		if c.RequestBOM {
+			if _, err := io.WriteString(pw, utf8BOM); err != nil {
+				return
+			}
+		}
+		enc := xml.NewEncoder(pw)
+		if c.Indent != "" {
+			enc.Indent("", c.Indent)
+		}
+		pw.CloseWithError(enc.Encode(env))
+	}()
+	return pr
+}
@@ -0,0 +1,174 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// headerMarker is the comment marking the place of the Header in the
+// envelope of a Template.
+const headerMarker = "soap:header"
+
+// placeholder matches the variables of a template, as in ${name}, and the
+// place of its Header.
+var placeholder = regexp.MustCompile(`\$\{(\w+)\}|<!--` + headerMarker + `-->`)
+
+// Template is a request envelope encoded once, for sending many requests
+// of the same structure that differ only in a few values. Each ${name}
+// placeholder written in a string field of the message is replaced, when
+// the template is sent, by the escaped value of the variable name. The
+// SOAP Header is encoded anew on every send, so that the values Addressing,
+// Timestamp or SecurityHeader generate per request stay fresh.
+type Template struct {
+	action string
+	header *Envelope // envelope holding the Header, nil if none
+	text   []string  // static parts; variable vars[i] goes between text[i] and text[i+1]
+	vars   []string  // names of the variables, "" for the Header
+	size   int       // length of the static parts
+}
+
+// templateHeader stands for the Header in the envelope of a Template.
+type templateHeader struct{}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (templateHeader) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeToken(xml.Comment(headerMarker))
+}
+
+// CompileTemplate encodes in as RoundTrip would, and returns the template
+// to send it with RoundTripTemplate.
+func (c *Client) CompileTemplate(in Message) (*Template, error) {
+	action := c.actionName(in)
+	env := c.envelope(newCallOptions(nil), action, in)
+	t := &Template{action: action}
+	if env.Header != nil {
+		header := *env
+		header.Body = nil
+		t.header = &header
+		env.Header = templateHeader{}
+	}
+	b, err := c.encode(env)
+	if err != nil {
+		return nil, err
+	}
+	last := 0
+	for _, m := range placeholder.FindAllSubmatchIndex(b, -1) {
+		t.text = append(t.text, string(b[last:m[0]]))
+		if m[2] < 0 {
+			t.vars = append(t.vars, "")
+		} else {
+			t.vars = append(t.vars, string(b[m[2]:m[3]]))
+		}
+		last = m[1]
+	}
+	t.text = append(t.text, string(b[last:]))
+	t.size = len(b)
+	return t, nil
+}
+
+// expand returns the envelope of t with the given variables and Header
+// substituted.
+func (t *Template) expand(vars map[string]string, header []byte) ([]byte, error) {
+	var b bytes.Buffer
+	b.Grow(t.size + len(header))
+	for i, name := range t.vars {
+		if name == "" {
+			b.WriteString(t.text[i])
+			b.Write(header)
+			continue
+		}
+		value, ok := vars[name]
+		if !ok {
+			return nil, fmt.Errorf("soap: template variable %q not set", name)
+		}
+		b.WriteString(t.text[i])
+		if err := xml.EscapeText(&b, []byte(value)); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteString(t.text[len(t.text)-1])
+	return b.Bytes(), nil
+}
+
+// RoundTripTemplate sends the envelope of t with the given variables
+// substituted, and decodes the response onto out as RoundTrip does.
+func (c *Client) RoundTripTemplate(t *Template, vars map[string]string, out Message) error {
+	return c.RoundTripTemplateContext(context.Background(), t, vars, out)
+}
+
+// RoundTripTemplateContext is like RoundTripTemplate, with a context
+// bounding the call.
+func (c *Client) RoundTripTemplateContext(ctx context.Context, t *Template, vars map[string]string, out Message) error {
+	o := newCallOptions(nil)
+	return c.doCall(ctx, o, t.action, func(ctx context.Context, rec *callRecord) error {
+		var header []byte
+		if t.header != nil {
+			var err error
+			if header, err = c.encodeHeader(t.header); err != nil {
+				return err
+			}
+		}
+		b, err := t.expand(vars, header)
+		if err != nil {
+			return err
+		}
+		if err := c.checkSize(b); err != nil {
+			return err
+		}
+		c.sent(ctx, b)
+		headerFunc := func(r *http.Request) {
+			ct := c.ContentType
+			if ct == "" {
+				ct = "text/xml;charset=" + c.charset()
+			}
+			c.setActionHeaders(r, ct, t.action, t.action != "")
+		}
+		if b, headerFunc, err = c.compress(b, headerFunc); err != nil {
+			return err
+		}
+		resp, err := c.post(ctx, o, t.action, headerFunc, true, func() io.Reader {
+			return bytes.NewReader(b)
+		})
+		if err != nil {
+			return err
+		}
+		rec.status, rec.closed = resp.StatusCode, resp.Close
+		return c.receive(ctx, o, resp, out)
+	})
+}
+
+// encodeHeader serializes the Header element of env as encode would within
+// the envelope.
+func (c *Client) encodeHeader(env *Envelope) ([]byte, error) {
+	var b bytes.Buffer
+	enc := xml.NewEncoder(&b)
+	if c.Indent != "" {
+		// as a child of the Envelope element
+		enc.Indent(c.Indent, c.Indent)
+	}
+	prefix, attrs := env.declarations()
+	if err := env.encodeHeader(enc, prefix, attrs.uris); err != nil {
+		return nil, &EncodeError{Type: env.messageType(), Err: err}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	out := b.Bytes()
+	if c.Indent != "" {
+		// the encoder does not indent the placeholder comment
+		out = append([]byte("\n"), out...)
+	}
+	if c.ExpandEmptyElements {
+		out = expandEmptyElements(out)
+	}
+	if c.CRLF {
+		out = bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n"))
+		out = bytes.ReplaceAll(out, []byte("\n"), []byte("\r\n"))
+	}
+	return out, nil
+}
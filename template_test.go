@@ -0,0 +1,117 @@
+package soap
+
+import (
+	"regexp"
+	"testing"
+)
+
+// quote is a request message of many fields, most of them static.
+type quote struct {
+	Symbol   string `xml:"tns:Symbol"`
+	Exchange string `xml:"tns:Exchange"`
+	Currency string `xml:"tns:Currency"`
+	Fields   []string
+	Depth    int  `xml:"tns:Depth"`
+	Realtime bool `xml:"tns:Realtime"`
+}
+
+func newQuote(symbol string) *quote {
+	q := &quote{Symbol: symbol, Exchange: "NYSE", Currency: "USD", Depth: 10, Realtime: true}
+	for i := 0; i < 8; i++ {
+		q.Fields = append(q.Fields, "bid", "ask", "last", "volume", "open", "close", "high", "low")
+	}
+	return q
+}
+
+// send returns the envelope RoundTripTemplate sends for t.
+func send(t testing.TB, c *Client, tmpl *Template, vars map[string]string) string {
+	var header []byte
+	if tmpl.header != nil {
+		var err error
+		if header, err = c.encodeHeader(tmpl.header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := tmpl.expand(vars, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestTemplateMatchesEncode(t *testing.T) {
+	for _, c := range []*Client{
+		{},
+		{Header: AuthHeader{Username: "u", Password: "p"}},
+		{Header: AuthHeader{Username: "u", Password: "p"}, Indent: "  "},
+		{Header: AuthHeader{Username: "u", Password: "p"}, Indent: "\t", CRLF: true, EnvelopePrefix: "s"},
+		{Headers: []Header{AuthHeader{Username: "u"}, AuthHeader{Password: "p"}}, ActionPlacement: ActionAll},
+	} {
+		tmpl, err := c.CompileTemplate(newQuote("${symbol}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := c.encode(c.envelope(newCallOptions(nil), tmpl.action, newQuote("A&B")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := send(t, c, tmpl, map[string]string{"symbol": "A&B"}); got != string(want) {
+			t.Errorf("template sent\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestTemplateHeaderPerSend(t *testing.T) {
+	c := &Client{Header: Addressing{To: "urn:to"}}
+	tmpl, err := c.CompileTemplate(newQuote("${symbol}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageID := regexp.MustCompile(`<wsa:MessageID[^>]*>([^<]+)<`)
+	first := messageID.FindStringSubmatch(send(t, c, tmpl, map[string]string{"symbol": "A"}))
+	second := messageID.FindStringSubmatch(send(t, c, tmpl, map[string]string{"symbol": "A"}))
+	if first == nil || second == nil {
+		t.Fatal("no wsa:MessageID sent")
+	}
+	if first[1] == second[1] {
+		t.Errorf("both sends have wsa:MessageID %s", first[1])
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	benchmarkEncode(b, &Client{})
+}
+
+func BenchmarkTemplate(b *testing.B) {
+	benchmarkTemplate(b, &Client{})
+}
+
+// The Header is encoded on every send, templates or not.
+func BenchmarkEncodeHeader(b *testing.B) {
+	benchmarkEncode(b, &Client{Header: Addressing{To: "urn:to"}})
+}
+
+func BenchmarkTemplateHeader(b *testing.B) {
+	benchmarkTemplate(b, &Client{Header: Addressing{To: "urn:to"}})
+}
+
+func benchmarkEncode(b *testing.B, c *Client) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.encode(c.envelope(newCallOptions(nil), "", newQuote("IBM"))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkTemplate(b *testing.B, c *Client) {
+	tmpl, err := c.CompileTemplate(newQuote("${symbol}"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := map[string]string{"symbol": "IBM"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		send(b, c, tmpl, vars)
+	}
+}
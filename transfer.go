@@ -0,0 +1,88 @@
+package soap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ByteBudgetError is returned, before anything is sent, by the calls of a
+// Client that has already transferred Client.ByteBudget bytes.
+type ByteBudgetError struct {
+	Used   int64 // bytes sent and received so far
+	Budget int64
+}
+
+func (e *ByteBudgetError) Error() string {
+	return fmt.Sprintf("soap: byte budget of %d exhausted (%d bytes transferred)", e.Budget, e.Used)
+}
+
+// Transferred returns the number of bytes of request and response bodies
+// sent and received by the Client so far, retries included, as they went
+// over the wire: compressed, if they were.
+func (c *Client) Transferred() (sent, received int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sentBytes, c.receivedBytes
+}
+
+// checkBytes fails if ByteBudget is set and exhausted.
+func (c *Client) checkBytes() error {
+	if c.ByteBudget <= 0 {
+		return nil
+	}
+	sent, received := c.Transferred()
+	if used := sent + received; used >= c.ByteBudget {
+		return &ByteBudgetError{Used: used, Budget: c.ByteBudget}
+	}
+	return nil
+}
+
+// countBytes adds n bytes to the sent or received total.
+func (c *Client) countBytes(n int64, received bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if received {
+		c.receivedBytes += n
+	} else {
+		c.sentBytes += n
+	}
+}
+
+// countingBody is a request or response body adding the bytes read from
+// it to the totals of a Client.
+type countingBody struct {
+	io.ReadCloser
+	c        *Client
+	received bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.c.countBytes(int64(n), b.received)
+	}
+	return n, err
+}
+
+// countingDoer is a doer adding the bytes of the request and response bodies
+// it carries to the totals of a Client, including those of the responses
+// discarded before a retry.
+type countingDoer struct {
+	doer
+	c *Client
+}
+
+// Do implements the doer interface.
+func (d countingDoer) Do(r *http.Request) (*http.Response, error) {
+	if r.Body != nil && r.Body != http.NoBody {
+		counted := *r // leave the request of the caller as it is
+		counted.Body = &countingBody{ReadCloser: r.Body, c: d.c}
+		r = &counted
+	}
+	resp, err := d.doer.Do(r)
+	if resp != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, c: d.c, received: true}
+	}
+	return resp, err
+}
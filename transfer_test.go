@@ -0,0 +1,92 @@
+package soap
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransferredCountsEveryAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(*Client)
+		reject   func(*http.Request) (status int, header http.Header) // 0 to accept
+		call     func(*Client) error
+		attempts int
+	}{
+		{
+			name: "SendEnvelope",
+			call: func(c *Client) error {
+				_, _, _, err := c.SendEnvelope(context.Background(), c.envelope(newCallOptions(nil), "", &ping{}))
+				return err
+			},
+			attempts: 1,
+		},
+		{
+			name:  "retried 503",
+			setup: func(c *Client) { c.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Jitter: NoJitter} },
+			reject: func(r *http.Request) (int, http.Header) {
+				return http.StatusServiceUnavailable, nil
+			},
+			call:     func(c *Client) error { return c.RoundTrip(&ping{}, &ping{}) },
+			attempts: 3,
+		},
+		{
+			name:  "digest retry",
+			setup: func(c *Client) { c.DigestAuth("user", "secret") },
+			reject: func(r *http.Request) (int, http.Header) {
+				if strings.HasPrefix(r.Header.Get("Authorization"), "Digest ") {
+					return 0, nil
+				}
+				return http.StatusUnauthorized, http.Header{"Www-Authenticate": {`Digest realm="soap", nonce="abc", qop="auth"`}}
+			},
+			call:     func(c *Client) error { return c.RoundTrip(&ping{}, &ping{}) },
+			attempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var sent, received int64
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				attempts++
+				sent += int64(len(b))
+				body := pongEnvelope
+				if tt.reject != nil {
+					if status, header := tt.reject(r); status != 0 {
+						for key, values := range header {
+							w.Header()[key] = values
+						}
+						body = "rejected"
+						w.WriteHeader(status)
+					}
+				}
+				n, _ := w.Write([]byte(body))
+				received += int64(n)
+			}))
+			defer srv.Close()
+
+			c := &Client{URL: srv.URL}
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			tt.call(c)
+			if attempts != tt.attempts {
+				t.Fatalf("server got %d requests, want %d", attempts, tt.attempts)
+			}
+			gotSent, gotReceived := c.Transferred()
+			if gotSent != sent || gotReceived != received {
+				t.Errorf("Transferred() = %d, %d; want %d, %d", gotSent, gotReceived, sent, received)
+			}
+		})
+	}
+}
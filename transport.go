@@ -0,0 +1,171 @@
+package soap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// httpClient returns the HTTP client for a call of the given action: the
+// per-call override if any, else the Client's own, with its transport
+// cloned once per ConnectionKey so that each key keeps separate
+// connections.
+func (c *Client) httpClient(o *callOptions, action string) *http.Client {
+	if o.httpClient != nil {
+		return o.httpClient
+	}
+	cli := c.Config
+	if cli == nil {
+		cli = c.defaultClient()
+	}
+	if c.ConnectionKey == nil {
+		return cli
+	}
+	rt := cli.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return cli
+	}
+
+	key := c.ConnectionKey(action)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if kc, ok := c.clients[key]; ok {
+		return kc
+	}
+	if c.clients == nil {
+		c.clients = make(map[string]*http.Client)
+	}
+	kc := *cli
+	kc.Transport = t.Clone()
+	c.clients[key] = &kc
+	return &kc
+}
+
+// EnableCookieJar makes the calls of the Client share cookies, as session
+// cookies set at login, by installing a cookie jar on Config if it has none.
+// Config is replaced by a copy rather than modified, since it may be shared.
+// It must not be called concurrently with calls.
+func (c *Client) EnableCookieJar() error {
+	if c.Config != nil && c.Config.Jar != nil {
+		return nil
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	cli := c.Config
+	if cli == nil {
+		cli = c.defaultClient()
+	}
+	withJar := *cli
+	withJar.Jar = jar
+	c.Config = &withJar
+	c.mu.Lock()
+	c.clients = nil // built from the previous Config
+	c.mu.Unlock()
+	return nil
+}
+
+// ErrUnexpectedRedirect is wrapped by the RedirectError returned for any
+// redirect when Client.NoFollowRedirects is set.
+var ErrUnexpectedRedirect = errors.New("soap: unexpected redirect")
+
+// defaultClient returns the HTTP client used when Config is nil: the
+// http.DefaultClient, unless settings of the Client call for a transport
+// of its own.
+func (c *Client) defaultClient() *http.Client {
+	if c.MaxResponseHeaderBytes <= 0 && c.MaxIdleConnsPerHost <= 0 && c.MaxConnsPerHost <= 0 {
+		return http.DefaultClient
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.own == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if c.MaxResponseHeaderBytes > 0 {
+			t.MaxResponseHeaderBytes = c.MaxResponseHeaderBytes
+		}
+		if c.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+		}
+		t.MaxConnsPerHost = c.MaxConnsPerHost
+		c.own = &http.Client{Transport: t}
+	}
+	return c.own
+}
+
+// RedirectError is returned when the server redirects a call in a way that
+// would not resend the SOAP request: on a 301 or 302 response to a POST the
+// HTTP client follows up with a GET without body, which would otherwise
+// surface as a confusing empty or unrelated response, unless
+// Client.FollowRedirectsAsPost is set. With Client.NoFollowRedirects it is
+// returned for every redirect, wrapping ErrUnexpectedRedirect. 307 and 308
+// redirects resend the request as it is, except for streamed requests,
+// which cannot be sent twice.
+type RedirectError struct {
+	StatusCode int    // status of the redirect response
+	Location   string // URL the server redirected to
+	Err        error  // ErrUnexpectedRedirect if redirects are not followed at all
+}
+
+func (e *RedirectError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %d to %q", e.Err, e.StatusCode, e.Location)
+	}
+	return fmt.Sprintf("soap: %d redirect to %q would resend the request as GET", e.StatusCode, e.Location)
+}
+
+func (e *RedirectError) Unwrap() error {
+	return e.Err
+}
+
+// guardRedirects returns a copy of cli that stops at redirects changing the
+// request method, or at any redirect if noFollow is set, recording them in
+// *redirect. Other redirects are left to the CheckRedirect policy of cli.
+func guardRedirects(cli *http.Client, noFollow bool, redirect **RedirectError) *http.Client {
+	g := *cli
+	check := cli.CheckRedirect
+	g.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if noFollow || req.Method != via[0].Method {
+			*redirect = &RedirectError{
+				StatusCode: req.Response.StatusCode,
+				Location:   req.URL.String(),
+			}
+			if noFollow {
+				(*redirect).Err = ErrUnexpectedRedirect
+			}
+			return http.ErrUseLastResponse
+		}
+		if check != nil {
+			return check(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &g
+}
+
+// credentialHeaders are the request headers that are not sent on to another
+// host when following a redirect.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// sameHost reports whether the URLs a and b name the same host and port.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
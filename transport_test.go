@@ -0,0 +1,92 @@
+package soap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ping is the content of a Body, as in and out of a round trip.
+type ping struct {
+	Ping *struct{} `xml:"Ping"`
+}
+
+const pongEnvelope = `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><Ping/></soapenv:Body></soapenv:Envelope>`
+
+func TestFollowRedirectsAsPostCrossHost(t *testing.T) {
+	var got http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(pongEnvelope))
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" || r.Header.Get("X-Token") == "" {
+			t.Errorf("credentials not sent to the original host")
+		}
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, FollowRedirectsAsPost: true}
+	c.BasicAuth("user", "secret")
+	if err := c.RoundTripWith(&ping{}, &ping{}, WithHeader("X-Token", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("redirect not followed")
+	}
+	for _, key := range []string{"Authorization", "X-Token"} {
+		if v := got.Get(key); v != "" {
+			t.Errorf("%s = %q sent to another host", key, v)
+		}
+	}
+
+	// credentials set by Pre
+	got = nil
+	c = &Client{URL: srv.URL, FollowRedirectsAsPost: true, Pre: func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer secret")
+		r.Header.Set("Cookie", "session=secret")
+		r.Header.Set("X-Token", "pre")
+	}}
+	if err := c.RoundTrip(&ping{}, &ping{}); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("redirect not followed")
+	}
+	if v := got.Get("X-Token"); v != "pre" {
+		t.Errorf("X-Token = %q, want the one set by Pre", v)
+	}
+	for _, key := range []string{"Authorization", "Cookie"} {
+		if v := got.Get(key); v != "" {
+			t.Errorf("%s = %q sent to another host", key, v)
+		}
+	}
+}
+
+func TestFollowRedirectsAsPostSameHost(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if _, _, ok := r.BasicAuth(); !ok {
+			t.Error("credentials not sent on the same host")
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(pongEnvelope))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL + "/old", FollowRedirectsAsPost: true}
+	c.BasicAuth("user", "secret")
+	if err := c.RoundTrip(&ping{}, &ping{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package soap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// FetchWSDL retrieves the service description published at the client URL
+// with the "wsdl" query, e.g. http://host/service?wsdl. The request goes
+// through the same HTTP client and Pre and Post hooks as SOAP calls, so
+// proxy, TLS and authentication settings apply. A status other than 200 is
+// returned as an *HTTPError.
+func (c *Client) FetchWSDL(ctx context.Context) ([]byte, error) {
+	url := c.URL
+	if strings.Contains(url, "?") {
+		url += "&wsdl"
+	} else {
+		url += "?wsdl"
+	}
+	r, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.Pre != nil {
+		c.Pre(r)
+	}
+	var d doer = c.httpClient(newCallOptions(nil), "")
+	if c.auth != nil {
+		d = authDoer{doer: d, cred: c.auth}
+	}
+	resp, err := d.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("soap: fetching WSDL: %w", err)
+	}
+	defer resp.Body.Close()
+	if c.Post != nil {
+		c.Post(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// read only the first MiB of the body in error case
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Msg:        string(body),
+		}
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("soap: reading WSDL: %w", err)
+	}
+	return b, nil
+}
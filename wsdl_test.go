@@ -0,0 +1,43 @@
+package soap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchWSDLAuth(t *testing.T) {
+	tests := []struct {
+		name string
+		auth func(*Client)
+	}{
+		{"Basic", func(c *Client) { c.BasicAuth("user", "secret") }},
+		{"Digest", func(c *Client) { c.DigestAuth("user", "secret") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth := r.Header.Get("Authorization")
+				if !strings.HasPrefix(auth, tt.name+" ") {
+					w.Header().Set("WWW-Authenticate", `Digest realm="wsdl", nonce="abc", qop="auth"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte("<definitions/>"))
+			}))
+			defer srv.Close()
+
+			c := &Client{URL: srv.URL}
+			tt.auth(c)
+			b, err := c.FetchWSDL(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "<definitions/>" {
+				t.Errorf("got %q", b)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package soap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeaderValueAndPointer(t *testing.T) {
+	for _, h := range []Header{
+		SecurityHeader{Username: "u", Password: "p"},
+		&SecurityHeader{Username: "u", Password: "p"},
+		SecurityHeader{Username: "u", Password: "p", Digest: true, Timestamp: &Timestamp{}},
+		Timestamp{},
+		&Timestamp{},
+	} {
+		c := &Client{Header: h}
+		b, err := c.encode(c.envelope(newCallOptions(nil), "", &ping{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(b)
+		if !strings.Contains(got, `<wsse:Security xmlns:wsse="`+WSSENamespace+`"`) {
+			t.Errorf("%T: no wsse:Security block:\n%s", h, got)
+		}
+		if strings.Contains(got, "<Username>") || strings.Contains(got, "<Digest>") {
+			t.Errorf("%T: encoded as a plain struct:\n%s", h, got)
+		}
+	}
+}